@@ -1,243 +1,661 @@
-package main
-
-import (
-    "bytes"
-    "crypto/rand"
-    "fmt"
-    "log"
-    "math/big"
-    "os"
-    "os/exec"
-    "strings"
-)
-
-// runCommand выполняет команду cmd[0] с аргументами cmd[1:] и отображает stdout/stderr
-// в реальном времени. При ошибке возвращает error, что прекращает выполнение.
-func runCommand(cmd []string, stdinData string) error {
-    if len(cmd) == 0 {
-        return fmt.Errorf("пустая команда")
-    }
-    command := exec.Command(cmd[0], cmd[1:]...)
-
-    if stdinData != "" {
-        command.Stdin = strings.NewReader(stdinData)
-    }
-
-    command.Stdout = os.Stdout
-    command.Stderr = os.Stderr
-
-    if err := command.Run(); err != nil {
-        return fmt.Errorf("команда '%s' вернула ошибку: %v",
-            strings.Join(cmd, " "), err)
-    }
-    return nil
-}
-
-// runCommandOutput запускает команду и возвращает её stdout как строку.
-// stderr при этом идёт в консоль.
-func runCommandOutput(cmd []string) (string, error) {
-    if len(cmd) == 0 {
-        return "", fmt.Errorf("пустая команда (output)")
-    }
-    command := exec.Command(cmd[0], cmd[1:]...)
-
-    // Ошибки выводим в консоль
-    command.Stderr = os.Stderr
-
-    var stdoutBuf bytes.Buffer
-    command.Stdout = &stdoutBuf
-
-    if err := command.Run(); err != nil {
-        return "", fmt.Errorf("команда '%s' вернула ошибку: %v",
-            strings.Join(cmd, " "), err)
-    }
-    return stdoutBuf.String(), nil
-}
-
-const (
-    // Файлы и пути, как в 3.sh
-    mariadbConf = "/etc/mysql/mariadb.conf.d/50-server.cnf"
-    autoDeploy  = "/root/auto_deploy/phpMyAdmin.txt"
-)
-
-// generateRandomString генерирует случайную строку длиной n символов
-// из набора [A-Za-z0-9], используя криптографический генератор случайных чисел.
-func generateRandomString(n int) (string, error) {
-    const chars = "ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz0123456789"
-    result := make([]byte, n)
-
-    // Длина алфавита
-    bigLen := big.NewInt(int64(len(chars)))
-
-    for i := 0; i < n; i++ {
-        // Получаем случайное число в диапазоне [0, len(chars))
-        num, err := rand.Int(rand.Reader, bigLen)
-        if err != nil {
-            return "", err
-        }
-        // Индексируем нужный символ
-        result[i] = chars[num.Int64()]
-    }
-
-    return string(result), nil
-}
-
-// =============================================
-// Шаги скрипта 3.sh
-// =============================================
-
-// Шаг 1. Удаляем тестовую базу и анонимных пользователей в MySQL.
-func step1RemoveTestDBAndAnonymous() error {
-    log.Println("[Шаг 1] Удаляю тестовую базу и анонимных пользователей в MySQL...")
-    // sudo mysql -u root -e "DROP DATABASE IF EXISTS test; DELETE FROM mysql.user WHERE User=''; FLUSH PRIVILEGES;"
-    return runCommand([]string{
-        "sudo", "mysql", "-u", "root",
-        "-e", "DROP DATABASE IF EXISTS test; DELETE FROM mysql.user WHERE User=''; FLUSH PRIVILEGES;",
-    }, "")
-}
-
-// Шаг 2. Перезапускаю mariadb...
-func step2RestartMariaDB() error {
-    log.Println("[Шаг 2] Перезапускаю mariadb...")
-    // systemctl restart mariadb
-    return runCommand([]string{"systemctl", "restart", "mariadb"}, "")
-}
-
-// Шаг 3. Настраиваю bind-address
-func step3SetBindAddress() error {
-    log.Println("[Шаг 3] Настраиваю bind-address в", mariadbConf, "...")
-
-    // sed -i '/bind-address/d' /etc/mysql/mariadb.conf.d/50-server.cnf
-    if err := runCommand([]string{
-        "sed", "-i", "/bind-address/d", mariadbConf,
-    }, ""); err != nil {
-        return err
-    }
-
-    // echo "bind-address = 127.0.0.1" >> /etc/mysql/mariadb.conf.d/50-server.cnf
-    cmdStr := fmt.Sprintf(`echo "bind-address = 127.0.0.1" >> %s`, mariadbConf)
-    if err := runCommand([]string{"sh", "-c", cmdStr}, ""); err != nil {
-        return err
-    }
-
-    return nil
-}
-
-// Шаг 4. Генерируем 19-символьный логин и пароль (Go-методом)
-func step4GenerateLoginAndPassword() (string, string, error) {
-    log.Println("[Шаг 4] Генерирую 19-символьный логин и пароль для MySQL (Go-метод)...")
-
-    login, err := generateRandomString(19)
-    if err != nil {
-        return "", "", fmt.Errorf("не удалось сгенерировать логин: %v", err)
-    }
-    password, err := generateRandomString(19)
-    if err != nil {
-        return "", "", fmt.Errorf("не удалось сгенерировать пароль: %v", err)
-    }
-
-    log.Printf("   Сгенерированный логин: %s\n", login)
-    log.Printf("   Сгенерированный пароль: %s\n", password)
-
-    return login, password, nil
-}
-
-// Шаг 5. Создаю MySQL-пользователя и даю ему права.
-func step5CreateMySQLUser(login, password string) error {
-    log.Println("[Шаг 5] Создаю MySQL-пользователя и даю ему права...")
-
-    // sudo mysql -u root -e "CREATE USER '$login'@'localhost' IDENTIFIED BY '$password';
-    //                        GRANT ALL ON *.* TO '$login'@'localhost';
-    //                        FLUSH PRIVILEGES;"
-    query := fmt.Sprintf(
-        "CREATE USER '%s'@'localhost' IDENTIFIED BY '%s'; GRANT ALL ON *.* TO '%s'@'localhost'; FLUSH PRIVILEGES;",
-        login, password, login,
-    )
-    return runCommand([]string{
-        "sudo", "mysql", "-u", "root", "-e", query,
-    }, "")
-}
-
-// Шаг 6. Создаю файл /root/auto_deploy/phpMyAdmin.txt с логином/паролем...
-func step6SaveCredentials(login, password string) error {
-    log.Println("[Шаг 6] Создаю файл", autoDeploy, "с логином/паролем...")
-
-    // mkdir -p /root/auto_deploy
-    if err := runCommand([]string{"mkdir", "-p", "/root/auto_deploy"}, ""); err != nil {
-        return err
-    }
-
-    // echo "$login|$password" > /root/auto_deploy/phpMyAdmin.txt
-    echoStr := fmt.Sprintf(`echo "%s|%s" > %s`, login, password, autoDeploy)
-    return runCommand([]string{"sh", "-c", echoStr}, "")
-}
-
-// Шаг 7. Перезапускаю mariadb...
-func step7RestartMariaDB() error {
-    log.Println("[Шаг 7] Перезапускаю mariadb...")
-    return runCommand([]string{"systemctl", "restart", "mariadb"}, "")
-}
-
-// Шаг 8. Проверяю конфигурацию Nginx...
-func step8CheckNginx() error {
-    log.Println("[Шаг 8] Проверяю конфигурацию Nginx (nginx -t)...")
-    return runCommand([]string{"nginx", "-t"}, "")
-}
-
-// Шаг 9. Перезапускаю Nginx...
-func step9RestartNginx() error {
-    log.Println("[Шаг 9] Перезапускаю Nginx...")
-    return runCommand([]string{"systemctl", "restart", "nginx"}, "")
-}
-
-func main() {
-    // Оформим пошаговое выполнение с проверкой после каждого шага
-    steps := []struct {
-        name string
-        fn   func() error
-    }{
-        {"[Шаг 1]", step1RemoveTestDBAndAnonymous},
-        {"[Шаг 2]", step2RestartMariaDB},
-        {"[Шаг 3]", step3SetBindAddress},
-    }
-
-    // Выполняем шаги 1-3
-    for i, step := range steps {
-        log.Println(step.name, "начало ...")
-        if err := step.fn(); err != nil {
-            log.Fatalf("[Ошибка на шаге %d]: %v", i+1, err)
-        }
-        log.Println(step.name, "выполнен успешно.")
-    }
-
-    // Шаг 4: Генерируем логин/пароль через Go-метод
-    log.Println("[Шаг 4] начало ...")
-    login, password, err := step4GenerateLoginAndPassword()
-    if err != nil {
-        log.Fatalf("[Ошибка на шаге 4]: %v", err)
-    }
-    log.Println("[Шаг 4] выполнен успешно.")
-
-    // Шаги 5-9, которым нужны login и password
-    nextSteps := []struct {
-        name string
-        fn   func() error
-    }{
-        {"[Шаг 5]", func() error { return step5CreateMySQLUser(login, password) }},
-        {"[Шаг 6]", func() error { return step6SaveCredentials(login, password) }},
-        {"[Шаг 7]", step7RestartMariaDB},
-        {"[Шаг 8]", step8CheckNginx},
-        {"[Шаг 9]", step9RestartNginx},
-    }
-
-    for j, step := range nextSteps {
-        stepNumber := j + 5 // потому что 5..9
-        log.Println(step.name, "начало ...")
-        if err := step.fn(); err != nil {
-            log.Fatalf("[Ошибка на шаге %d]: %v", stepNumber, err)
-        }
-        log.Println(step.name, "выполнен успешно.")
-    }
-
-    log.Println("Все шаги выполнены успешно!")
-}
+package main
+
+import (
+    "bytes"
+    "crypto/rand"
+    "encoding/binary"
+    "errors"
+    "fmt"
+    "io"
+    "log"
+    "math/big"
+    "net"
+    "os"
+    "os/exec"
+    "strings"
+    "time"
+)
+
+// runCommand выполняет команду cmd[0] с аргументами cmd[1:] и отображает stdout/stderr
+// в реальном времени. При ошибке возвращает error, что прекращает выполнение.
+func runCommand(cmd []string, stdinData string) error {
+    if len(cmd) == 0 {
+        return fmt.Errorf("пустая команда")
+    }
+    command := exec.Command(cmd[0], cmd[1:]...)
+
+    if stdinData != "" {
+        command.Stdin = strings.NewReader(stdinData)
+    }
+
+    command.Stdout = os.Stdout
+    command.Stderr = os.Stderr
+
+    if err := command.Run(); err != nil {
+        return fmt.Errorf("команда '%s' вернула ошибку: %v",
+            strings.Join(cmd, " "), err)
+    }
+    return nil
+}
+
+// runCommandOutput запускает команду и возвращает её stdout как строку.
+// stderr при этом идёт в консоль.
+func runCommandOutput(cmd []string) (string, error) {
+    if len(cmd) == 0 {
+        return "", fmt.Errorf("пустая команда (output)")
+    }
+    command := exec.Command(cmd[0], cmd[1:]...)
+
+    // Ошибки выводим в консоль
+    command.Stderr = os.Stderr
+
+    var stdoutBuf bytes.Buffer
+    command.Stdout = &stdoutBuf
+
+    if err := command.Run(); err != nil {
+        return "", fmt.Errorf("команда '%s' вернула ошибку: %v",
+            strings.Join(cmd, " "), err)
+    }
+    return stdoutBuf.String(), nil
+}
+
+const (
+    // Файлы и пути, как в 3.sh
+    mariadbConf   = "/etc/mysql/mariadb.conf.d/50-server.cnf"
+    mariadbSocket = "/run/mysqld/mysqld.sock"
+    autoDeploy    = "/root/auto_deploy/phpMyAdmin.txt"
+)
+
+// generateRandomString генерирует случайную строку длиной n символов
+// из набора [A-Za-z0-9], используя криптографический генератор случайных чисел.
+func generateRandomString(n int) (string, error) {
+    const chars = "ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz0123456789"
+    result := make([]byte, n)
+
+    // Длина алфавита
+    bigLen := big.NewInt(int64(len(chars)))
+
+    for i := 0; i < n; i++ {
+        // Получаем случайное число в диапазоне [0, len(chars))
+        num, err := rand.Int(rand.Reader, bigLen)
+        if err != nil {
+            return "", err
+        }
+        // Индексируем нужный символ
+        result[i] = chars[num.Int64()]
+    }
+
+    return string(result), nil
+}
+
+// =============================================
+// Клиент MariaDB (протокол MySQL поверх unix-сокета)
+// =============================================
+
+// Урезанная копия драйвера из autodeploy/mysql.go (раздел (29)): вход root
+// без пароля, текстовые запросы, параметры «?» подставляются на стороне
+// клиента с экранированием. Оставлено только то, что нужно шагам ниже; разбор
+// пакетов, вход и экранирование правятся в обоих файлах одновременно и
+// проверяются тестами autodeploy/mysql_test.go.
+
+// mysqlTimeout — срок на подключение и один запрос.
+const mysqlTimeout = 30 * time.Second
+
+// mysqlExec выполняет запрос, не возвращающий строк.
+func mysqlExec(query string, args ...string) error {
+    _, err := mysqlQuery(query, args)
+    return err
+}
+
+// mysqlColumn выполняет SELECT и возвращает первый столбец всех строк.
+func mysqlColumn(query string, args ...string) ([]string, error) {
+    rows, err := mysqlQuery(query, args)
+    if err != nil {
+        return nil, err
+    }
+    var out []string
+    for _, row := range rows {
+        out = append(out, row[0])
+    }
+    return out, nil
+}
+
+// mysqlQuery подключается к MariaDB на время одного запроса: шаги 2 и 7
+// перезапускают сервер, и постоянное соединение всё равно бы оборвалось.
+// Как и `sudo mysql`, root входит по unix_socket, поэтому скрипт
+// запускается от root.
+func mysqlQuery(query string, args []string) ([][]string, error) {
+    nc, err := net.DialTimeout("unix", mariadbSocket, mysqlTimeout)
+    if err != nil {
+        return nil, err
+    }
+    defer nc.Close()
+    nc.SetDeadline(time.Now().Add(mysqlTimeout))
+    c := &mysqlConn{nc: nc}
+    if err := c.handshake("root"); err != nil {
+        return nil, fmt.Errorf("вход в MySQL: %w", err)
+    }
+    q, err := mysqlInterpolate(query, args, c.noBackslash)
+    if err != nil {
+        return nil, err
+    }
+    rows, err := c.query(q)
+    if err != nil {
+        return nil, err
+    }
+    c.seq = 0
+    c.writePacket([]byte{0x01}) // COM_QUIT
+    return rows, nil
+}
+
+// mysqlError — ошибка, которую вернул сервер (ERR-пакет).
+type mysqlError struct {
+    Code  uint16
+    State string
+    Msg   string
+}
+
+func (e *mysqlError) Error() string {
+    return fmt.Sprintf("MySQL %d (%s): %s", e.Code, e.State, e.Msg)
+}
+
+var errMySQLPacket = errors.New("MySQL: неожиданный ответ сервера")
+
+// Флаги возможностей клиента и статуса сервера (протокол MySQL 4.1+).
+const (
+    mysqlClientLongPassword  = 0x1
+    mysqlClientLongFlag      = 0x4
+    mysqlClientProtocol41    = 0x200
+    mysqlClientTransactions  = 0x2000
+    mysqlClientSecureConn    = 0x8000
+    mysqlClientPluginAuth    = 0x80000
+    mysqlStatusNoBackslashes = 0x200
+    mysqlMaxPacket           = 0xffffff
+)
+
+type mysqlConn struct {
+    nc          net.Conn
+    seq         byte
+    noBackslash bool // сервер в режиме NO_BACKSLASH_ESCAPES: \ в строках — обычный символ
+}
+
+func (c *mysqlConn) readPacket() ([]byte, error) {
+    var payload []byte
+    for {
+        var hdr [4]byte
+        if _, err := io.ReadFull(c.nc, hdr[:]); err != nil {
+            return nil, err
+        }
+        n := int(hdr[0]) | int(hdr[1])<<8 | int(hdr[2])<<16
+        c.seq = hdr[3] + 1
+        buf := make([]byte, n)
+        if _, err := io.ReadFull(c.nc, buf); err != nil {
+            return nil, err
+        }
+        payload = append(payload, buf...)
+        if n < mysqlMaxPacket {
+            if len(payload) == 0 {
+                return nil, errMySQLPacket
+            }
+            return payload, nil
+        }
+    }
+}
+
+func (c *mysqlConn) writePacket(data []byte) error {
+    for {
+        n := len(data)
+        if n > mysqlMaxPacket {
+            n = mysqlMaxPacket
+        }
+        pkt := append([]byte{byte(n), byte(n >> 8), byte(n >> 16), c.seq}, data[:n]...)
+        c.seq++
+        if _, err := c.nc.Write(pkt); err != nil {
+            return err
+        }
+        data = data[n:]
+        if n < mysqlMaxPacket {
+            return nil
+        }
+    }
+}
+
+func parseMySQLError(pkt []byte) error {
+    if len(pkt) < 3 {
+        return errMySQLPacket
+    }
+    e := &mysqlError{Code: binary.LittleEndian.Uint16(pkt[1:3])}
+    msg := pkt[3:]
+    if len(msg) >= 6 && msg[0] == '#' {
+        e.State, msg = string(msg[1:6]), msg[6:]
+    }
+    e.Msg = string(msg)
+    return e
+}
+
+// handshake — вход без пароля: root на сервере аутентифицируется плагином
+// unix_socket (auth_socket) по владельцу процесса, ответ клиента не нужен.
+// Пароли клиент не поддерживает: если сервер требует пароль, возвращается
+// ошибка mysqlPasswordError, а не просто «Access denied».
+func (c *mysqlConn) handshake(user string) error {
+    pkt, err := c.readPacket()
+    if err != nil {
+        return err
+    }
+    if pkt[0] == 0xff {
+        return parseMySQLError(pkt)
+    }
+    if pkt[0] != 10 {
+        return fmt.Errorf("неизвестная версия протокола MySQL %d", pkt[0])
+    }
+    // версия сервера\0, id соединения (4), соль (8), 0, возможности (2),
+    // кодировка (1), статус (2), возможности (2), …
+    rest := pkt[1:]
+    i := bytes.IndexByte(rest, 0)
+    if i < 0 || len(rest) < i+1+4+8+1+2 {
+        return errMySQLPacket
+    }
+    rest = rest[i+1+4+8+1:]
+    caps := uint32(binary.LittleEndian.Uint16(rest))
+    if len(rest) >= 7 {
+        c.noBackslash = binary.LittleEndian.Uint16(rest[3:])&mysqlStatusNoBackslashes != 0
+        caps |= uint32(binary.LittleEndian.Uint16(rest[5:])) << 16
+    }
+    if caps&mysqlClientProtocol41 == 0 {
+        return fmt.Errorf("сервер MySQL не поддерживает протокол 4.1")
+    }
+    flags := uint32(mysqlClientLongPassword|mysqlClientLongFlag|mysqlClientProtocol41|
+        mysqlClientTransactions|mysqlClientSecureConn|mysqlClientPluginAuth) & caps
+    resp := binary.LittleEndian.AppendUint32(nil, flags)
+    resp = binary.LittleEndian.AppendUint32(resp, mysqlMaxPacket)
+    resp = append(resp, 45) // utf8mb4_general_ci
+    resp = append(resp, make([]byte, 23)...)
+    resp = append(append(resp, user...), 0)
+    resp = append(resp, 0) // пустой ответ аутентификации
+    if flags&mysqlClientPluginAuth != 0 {
+        resp = append(append(resp, "mysql_native_password"...), 0)
+    }
+    if err := c.writePacket(resp); err != nil {
+        return err
+    }
+    plugin := "mysql_native_password"
+    for {
+        pkt, err := c.readPacket()
+        if err != nil {
+            return err
+        }
+        switch pkt[0] {
+        case 0x00:
+            return nil
+        case 0xff:
+            err := parseMySQLError(pkt)
+            var me *mysqlError
+            if errors.As(err, &me) && (me.Code == 1045 || me.Code == 1698) {
+                // Access denied: пустой ответ не подошёл плагину plugin
+                return mysqlPasswordError(user, plugin, err)
+            }
+            return err
+        case 0xfe:
+            // AuthSwitchRequest: имя плагина\0, соль. Пароля нет, ответ любого
+            // плагина пустой; если плагину нужен пароль, сервер ответит ERR
+            if i := bytes.IndexByte(pkt[1:], 0); i >= 0 {
+                plugin = string(pkt[1 : 1+i])
+            }
+            if err := c.writePacket(nil); err != nil {
+                return err
+            }
+        case 0x01:
+            // AuthMoreData (caching_sha2_password): 3 — вход по кешу, ждём OK;
+            // 4 — серверу нужен полный пароль
+            if len(pkt) > 1 && pkt[1] == 4 {
+                return mysqlPasswordError(user, "caching_sha2_password", nil)
+            }
+        default:
+            return errMySQLPacket
+        }
+    }
+}
+
+// mysqlPasswordError — сервер требует пароль для user, а клиент входит только
+// без пароля (root по unix_socket). cause — ERR-пакет сервера, если он был.
+func mysqlPasswordError(user, plugin string, cause error) error {
+    msg := fmt.Sprintf("MySQL требует пароль для %s@localhost (плагин %s), а вход возможен только без пароля "+
+        "по unix_socket: запустите от root и проверьте, что у %s@localhost плагин unix_socket", user, plugin, user)
+    if cause != nil {
+        return fmt.Errorf("%s: %w", msg, cause)
+    }
+    return errors.New(msg)
+}
+
+// readLenEnc читает целое переменной длины; null — значение NULL (0xfb).
+func readLenEnc(b []byte) (v uint64, null bool, n int) {
+    if len(b) == 0 {
+        return 0, false, 0
+    }
+    switch b[0] {
+    case 0xfb:
+        return 0, true, 1
+    case 0xfc:
+        n = 3
+    case 0xfd:
+        n = 4
+    case 0xfe:
+        n = 9
+    default:
+        return uint64(b[0]), false, 1
+    }
+    if len(b) < n {
+        return 0, false, 0
+    }
+    for i := n - 1; i >= 1; i-- {
+        v = v<<8 | uint64(b[i])
+    }
+    return v, false, n
+}
+
+// readLenEncString читает строку с длиной переменной длины.
+func readLenEncString(b []byte) (s []byte, null bool, n int, err error) {
+    l, null, n := readLenEnc(b)
+    if n == 0 || uint64(len(b)-n) < l {
+        return nil, false, 0, errMySQLPacket
+    }
+    return b[n : n+int(l)], null, n + int(l), nil
+}
+
+// query отправляет COM_QUERY и читает ответ: OK-пакет или набор строк
+// (NULL читается как пустая строка).
+func (c *mysqlConn) query(q string) ([][]string, error) {
+    c.seq = 0
+    if err := c.writePacket(append([]byte{0x03}, q...)); err != nil {
+        return nil, err
+    }
+    pkt, err := c.readPacket()
+    if err != nil {
+        return nil, err
+    }
+    switch pkt[0] {
+    case 0x00:
+        return nil, nil
+    case 0xff:
+        return nil, parseMySQLError(pkt)
+    case 0xfb:
+        return nil, fmt.Errorf("MySQL: LOAD DATA LOCAL не поддерживается")
+    }
+    // Определения столбцов не нужны: пропускаем их до EOF-пакета
+    count, _, _ := readLenEnc(pkt)
+    for {
+        if pkt, err = c.readPacket(); err != nil {
+            return nil, err
+        }
+        if pkt[0] == 0xfe && len(pkt) < 9 {
+            break
+        }
+    }
+    var rows [][]string
+    for {
+        pkt, err := c.readPacket()
+        if err != nil {
+            return nil, err
+        }
+        if pkt[0] == 0xfe && len(pkt) < 9 {
+            return rows, nil
+        }
+        if pkt[0] == 0xff {
+            return nil, parseMySQLError(pkt)
+        }
+        row := make([]string, count)
+        for i, off := 0, 0; i < len(row); i++ {
+            s, _, n, err := readLenEncString(pkt[off:])
+            if err != nil {
+                return nil, err
+            }
+            row[i] = string(s)
+            off += n
+        }
+        rows = append(rows, row)
+    }
+}
+
+// mysqlInterpolate подставляет параметры вместо «?» вне строк и имён в кавычках.
+func mysqlInterpolate(query string, args []string, noBackslash bool) (string, error) {
+    if len(args) == 0 {
+        return query, nil
+    }
+    var b strings.Builder
+    n := 0
+    var quote byte
+    for i := 0; i < len(query); i++ {
+        ch := query[i]
+        switch {
+        case quote != 0:
+            if ch == quote {
+                quote = 0
+            } else if ch == '\\' && quote != '`' && !noBackslash && i+1 < len(query) {
+                b.WriteByte(ch)
+                i++
+                ch = query[i]
+            }
+        case ch == '\'' || ch == '"' || ch == '`':
+            quote = ch
+        case ch == '?':
+            if n >= len(args) {
+                return "", fmt.Errorf("MySQL: в запросе больше «?», чем параметров (%d)", len(args))
+            }
+            b.WriteString(mysqlQuote(args[n], noBackslash))
+            n++
+            continue
+        }
+        b.WriteByte(ch)
+    }
+    if n != len(args) {
+        return "", fmt.Errorf("MySQL: параметров %d, а «?» в запросе %d", len(args), n)
+    }
+    return b.String(), nil
+}
+
+// mysqlQuote — строка в одинарных кавычках. В UTF-8 байты экранируемых
+// символов не встречаются внутри многобайтных, поэтому идём по байтам.
+func mysqlQuote(s string, noBackslash bool) string {
+    var b strings.Builder
+    b.WriteByte('\'')
+    for i := 0; i < len(s); i++ {
+        ch := s[i]
+        if noBackslash {
+            if ch == '\'' {
+                b.WriteByte('\'')
+            }
+            b.WriteByte(ch)
+            continue
+        }
+        switch ch {
+        case 0:
+            b.WriteString(`\0`)
+        case '\n':
+            b.WriteString(`\n`)
+        case '\r':
+            b.WriteString(`\r`)
+        case '\\':
+            b.WriteString(`\\`)
+        case '\'':
+            b.WriteString(`\'`)
+        case '"':
+            b.WriteString(`\"`)
+        case 0x1a:
+            b.WriteString(`\Z`)
+        default:
+            b.WriteByte(ch)
+        }
+    }
+    b.WriteByte('\'')
+    return b.String()
+}
+
+// =============================================
+// Шаги скрипта 3.sh
+// =============================================
+
+// Шаг 1. Удаляем тестовую базу и анонимных пользователей в MySQL.
+func step1RemoveTestDBAndAnonymous() error {
+    log.Println("[Шаг 1] Удаляю тестовую базу и анонимных пользователей в MySQL...")
+    if err := mysqlExec("DROP DATABASE IF EXISTS `test`"); err != nil {
+        return err
+    }
+    // В MariaDB 10.4+ mysql.user — представление, DELETE из него не работает:
+    // удаляем анонимных пользователей через DROP USER
+    hosts, err := mysqlColumn("SELECT Host FROM mysql.user WHERE User = ''")
+    if err != nil {
+        return err
+    }
+    for _, host := range hosts {
+        if err := mysqlExec("DROP USER ''@?", host); err != nil {
+            return err
+        }
+    }
+    err = mysqlExec("FLUSH PRIVILEGES")
+    return err
+}
+
+// Шаг 2. Перезапускаю mariadb...
+func step2RestartMariaDB() error {
+    log.Println("[Шаг 2] Перезапускаю mariadb...")
+    // systemctl restart mariadb
+    return runCommand([]string{"systemctl", "restart", "mariadb"}, "")
+}
+
+// Шаг 3. Настраиваю bind-address
+func step3SetBindAddress() error {
+    log.Println("[Шаг 3] Настраиваю bind-address в", mariadbConf, "...")
+
+    // sed -i '/bind-address/d' /etc/mysql/mariadb.conf.d/50-server.cnf
+    if err := runCommand([]string{
+        "sed", "-i", "/bind-address/d", mariadbConf,
+    }, ""); err != nil {
+        return err
+    }
+
+    // echo "bind-address = 127.0.0.1" >> /etc/mysql/mariadb.conf.d/50-server.cnf
+    cmdStr := fmt.Sprintf(`echo "bind-address = 127.0.0.1" >> %s`, mariadbConf)
+    if err := runCommand([]string{"sh", "-c", cmdStr}, ""); err != nil {
+        return err
+    }
+
+    return nil
+}
+
+// Шаг 4. Генерируем 19-символьный логин и пароль (Go-методом)
+func step4GenerateLoginAndPassword() (string, string, error) {
+    log.Println("[Шаг 4] Генерирую 19-символьный логин и пароль для MySQL (Go-метод)...")
+
+    login, err := generateRandomString(19)
+    if err != nil {
+        return "", "", fmt.Errorf("не удалось сгенерировать логин: %v", err)
+    }
+    password, err := generateRandomString(19)
+    if err != nil {
+        return "", "", fmt.Errorf("не удалось сгенерировать пароль: %v", err)
+    }
+
+    log.Printf("   Сгенерированный логин: %s\n", login)
+    log.Printf("   Сгенерированный пароль: %s\n", password)
+
+    return login, password, nil
+}
+
+// Шаг 5. Создаю MySQL-пользователя и даю ему права.
+func step5CreateMySQLUser(login, password string) error {
+    log.Println("[Шаг 5] Создаю MySQL-пользователя и даю ему права...")
+
+    // Логин и пароль передаются параметрами, драйвер их экранирует
+    if err := mysqlExec("CREATE USER ?@'localhost' IDENTIFIED BY ?", login, password); err != nil {
+        return err
+    }
+    if err := mysqlExec("GRANT ALL ON *.* TO ?@'localhost'", login); err != nil {
+        return err
+    }
+    err := mysqlExec("FLUSH PRIVILEGES")
+    return err
+}
+
+// Шаг 6. Создаю файл /root/auto_deploy/phpMyAdmin.txt с логином/паролем...
+func step6SaveCredentials(login, password string) error {
+    log.Println("[Шаг 6] Создаю файл", autoDeploy, "с логином/паролем...")
+
+    // mkdir -p /root/auto_deploy
+    if err := runCommand([]string{"mkdir", "-p", "/root/auto_deploy"}, ""); err != nil {
+        return err
+    }
+
+    // echo "$login|$password" > /root/auto_deploy/phpMyAdmin.txt
+    echoStr := fmt.Sprintf(`echo "%s|%s" > %s`, login, password, autoDeploy)
+    return runCommand([]string{"sh", "-c", echoStr}, "")
+}
+
+// Шаг 7. Перезапускаю mariadb...
+func step7RestartMariaDB() error {
+    log.Println("[Шаг 7] Перезапускаю mariadb...")
+    return runCommand([]string{"systemctl", "restart", "mariadb"}, "")
+}
+
+// Шаг 8. Проверяю конфигурацию Nginx...
+func step8CheckNginx() error {
+    log.Println("[Шаг 8] Проверяю конфигурацию Nginx (nginx -t)...")
+    return runCommand([]string{"nginx", "-t"}, "")
+}
+
+// Шаг 9. Перезапускаю Nginx...
+func step9RestartNginx() error {
+    log.Println("[Шаг 9] Перезапускаю Nginx...")
+    return runCommand([]string{"systemctl", "restart", "nginx"}, "")
+}
+
+func main() {
+    // Оформим пошаговое выполнение с проверкой после каждого шага
+    steps := []struct {
+        name string
+        fn   func() error
+    }{
+        {"[Шаг 1]", step1RemoveTestDBAndAnonymous},
+        {"[Шаг 2]", step2RestartMariaDB},
+        {"[Шаг 3]", step3SetBindAddress},
+    }
+
+    // Выполняем шаги 1-3
+    for i, step := range steps {
+        log.Println(step.name, "начало ...")
+        if err := step.fn(); err != nil {
+            log.Fatalf("[Ошибка на шаге %d]: %v", i+1, err)
+        }
+        log.Println(step.name, "выполнен успешно.")
+    }
+
+    // Шаг 4: Генерируем логин/пароль через Go-метод
+    log.Println("[Шаг 4] начало ...")
+    login, password, err := step4GenerateLoginAndPassword()
+    if err != nil {
+        log.Fatalf("[Ошибка на шаге 4]: %v", err)
+    }
+    log.Println("[Шаг 4] выполнен успешно.")
+
+    // Шаги 5-9, которым нужны login и password
+    nextSteps := []struct {
+        name string
+        fn   func() error
+    }{
+        {"[Шаг 5]", func() error { return step5CreateMySQLUser(login, password) }},
+        {"[Шаг 6]", func() error { return step6SaveCredentials(login, password) }},
+        {"[Шаг 7]", step7RestartMariaDB},
+        {"[Шаг 8]", step8CheckNginx},
+        {"[Шаг 9]", step9RestartNginx},
+    }
+
+    for j, step := range nextSteps {
+        stepNumber := j + 5 // потому что 5..9
+        log.Println(step.name, "начало ...")
+        if err := step.fn(); err != nil {
+            log.Fatalf("[Ошибка на шаге %d]: %v", stepNumber, err)
+        }
+        log.Println(step.name, "выполнен успешно.")
+    }
+
+    log.Println("Все шаги выполнены успешно!")
+}
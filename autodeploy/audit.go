@@ -0,0 +1,192 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// ------------------------------
+// (22) Журнал аудита (цепочка хешей)
+// ------------------------------
+
+// auditEntry — запись журнала аудита AUDIT_LOG (одна JSON-строка). Hash —
+// sha256 от Prev и самой записи с пустым Hash, поэтому изменение, вставка
+// или удаление любой строки ломает цепочку, что и находит `autodeploy audit verify`.
+type auditEntry struct {
+	Seq     int64  `json:"seq"`
+	Time    string `json:"time"`
+	Action  string `json:"action"` // db_create, db_drop, nginx_write, nginx_remove, cert_delete, cf_patch, ...
+	Target  string `json:"target"`
+	Domain  string `json:"domain,omitempty"`
+	Details string `json:"details,omitempty"`
+	Result  string `json:"result"`  // ok или текст ошибки
+	Trigger string `json:"trigger"` // inotify:<путь>, resume:<журнал>, cli:<команда>
+	Actor   string `json:"actor"`   // пользователь ОС, запустивший процесс
+	Pid     int    `json:"pid"`
+	Prev    string `json:"prev"`
+	Hash    string `json:"hash"`
+}
+
+// auditGenesis — Prev первой записи.
+var auditGenesis = strings.Repeat("0", 64)
+
+func (e auditEntry) computeHash() string {
+	e.Hash = ""
+	data, _ := json.Marshal(e)
+	sum := sha256.Sum256(append([]byte(e.Prev+"\n"), data...))
+	return hex.EncodeToString(sum[:])
+}
+
+// auditActor — пользователь процесса; для запуска через sudo — и исходный пользователь.
+func auditActor() string {
+	actor := fmt.Sprintf("uid=%d", os.Getuid())
+	if u := os.Getenv("USER"); u != "" {
+		actor = u + " (" + actor + ")"
+	}
+	if su := os.Getenv("SUDO_USER"); su != "" {
+		actor += " sudo:" + su
+	}
+	return actor
+}
+
+// audit записывает изменяющее действие деплоя d.
+func (d *deployment) audit(action, target, details string, err error) {
+	trigger := d.Trigger
+	if trigger == "" {
+		trigger = "unknown"
+	}
+	auditRecord(auditEntry{Action: action, Target: target, Domain: d.Domain, Details: details, Trigger: trigger}, err)
+}
+
+// auditRecord дописывает запись в AUDIT_LOG. Файл блокируется flock, так как
+// писать могут и демон, и команды (retry) одновременно.
+func auditRecord(e auditEntry, err error) {
+	e.Result = "ok"
+	if err != nil {
+		e.Result = err.Error()
+	}
+	e.Time = time.Now().UTC().Format(time.RFC3339Nano)
+	e.Actor = auditActor()
+	e.Pid = os.Getpid()
+	if err := appendAudit(&e); err != nil {
+		log.Printf("[ERROR] Журнал аудита %s: %v (действие %s %s)", AUDIT_LOG, err, e.Action, e.Target)
+	}
+}
+
+func appendAudit(e *auditEntry) error {
+	if err := os.MkdirAll(filepath.Dir(AUDIT_LOG), 0700); err != nil {
+		return err
+	}
+	f, err := os.OpenFile(AUDIT_LOG, os.O_CREATE|os.O_RDWR|os.O_APPEND, 0600)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	if err := syscall.Flock(int(f.Fd()), syscall.LOCK_EX); err != nil {
+		return err
+	}
+	defer syscall.Flock(int(f.Fd()), syscall.LOCK_UN)
+	last, err := lastAuditEntry(f)
+	if err != nil {
+		return err
+	}
+	e.Seq, e.Prev = 1, auditGenesis
+	if last != nil {
+		e.Seq, e.Prev = last.Seq+1, last.Hash
+	}
+	e.Hash = e.computeHash()
+	data, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(append(data, '\n')); err != nil {
+		return err
+	}
+	return f.Sync()
+}
+
+// lastAuditEntry читает последнюю строку файла (хвост до 64 КБ).
+func lastAuditEntry(f *os.File) (*auditEntry, error) {
+	fi, err := f.Stat()
+	if err != nil || fi.Size() == 0 {
+		return nil, err
+	}
+	off := fi.Size() - 64<<10
+	if off < 0 {
+		off = 0
+	}
+	buf := make([]byte, fi.Size()-off)
+	if _, err := f.ReadAt(buf, off); err != nil && err != io.EOF {
+		return nil, err
+	}
+	lines := strings.Split(strings.TrimRight(string(buf), "\n"), "\n")
+	e := &auditEntry{}
+	if err := json.Unmarshal([]byte(lines[len(lines)-1]), e); err != nil {
+		return nil, fmt.Errorf("последняя запись повреждена: %v", err)
+	}
+	return e, nil
+}
+
+// cmdAudit — `autodeploy audit verify [файл]`: проверяет цепочку хешей.
+// Печатает хеш последней записи: его стоит сохранять вне сервера, тогда
+// обнаружится и обрезка журнала с конца.
+func cmdAudit(args []string) int {
+	if len(args) < 1 || args[0] != "verify" {
+		fmt.Fprintln(os.Stderr, "Использование: autodeploy audit verify [файл]")
+		return 2
+	}
+	path := AUDIT_LOG
+	if len(args) > 1 {
+		path = args[1]
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "[ERROR]", err)
+		return 1
+	}
+	seq, last, err := verifyAudit(data)
+	if err != nil {
+		fmt.Println("НАРУШЕНИЕ:", err)
+		return 1
+	}
+	fmt.Printf("OK: %s, записей: %d, последний хеш: %s\n", path, seq, last)
+	return 0
+}
+
+// verifyAudit проверяет цепочку хешей журнала аудита data и возвращает число
+// записей и хеш последней. Ошибка описывает первую испорченную строку.
+func verifyAudit(data []byte) (int64, string, error) {
+	prev, seq := auditGenesis, int64(0)
+	lines := strings.Split(strings.TrimRight(string(data), "\n"), "\n")
+	if len(data) == 0 {
+		lines = nil
+	}
+	for i, line := range lines {
+		var e auditEntry
+		if err := json.Unmarshal([]byte(line), &e); err != nil {
+			return seq, prev, fmt.Errorf("строка %d не читается: %v", i+1, err)
+		}
+		canonical, _ := json.Marshal(e)
+		switch {
+		case string(canonical) != line:
+			return seq, prev, fmt.Errorf("строка %d изменена (лишние или переставленные поля)", i+1)
+		case e.Seq != seq+1:
+			return seq, prev, fmt.Errorf("строка %d: seq=%d, ожидался %d (запись удалена или вставлена)", i+1, e.Seq, seq+1)
+		case e.Prev != prev:
+			return seq, prev, fmt.Errorf("строка %d (seq %d): prev не совпадает с хешем предыдущей записи", i+1, e.Seq)
+		case e.computeHash() != e.Hash:
+			return seq, prev, fmt.Errorf("строка %d (seq %d, %s %s): хеш не совпадает, запись изменена", i+1, e.Seq, e.Action, e.Target)
+		}
+		prev, seq = e.Hash, e.Seq
+	}
+	return seq, prev, nil
+}
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// ------------------------------
+// Журнал аудита (раздел (22)): цепочка хешей
+// ------------------------------
+
+func TestVerifyAudit(t *testing.T) {
+	oldLog := AUDIT_LOG
+	AUDIT_LOG = filepath.Join(t.TempDir(), "audit.log")
+	defer func() { AUDIT_LOG = oldLog }()
+	for _, action := range []string{"db_create", "nginx_write", "db_drop"} {
+		auditRecord(auditEntry{Action: action, Target: "t", Domain: "example.com", Details: action, Trigger: "test"}, nil)
+	}
+	data, err := os.ReadFile(AUDIT_LOG)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSuffix(string(data), "\n"), "\n")
+	join := func(l ...string) string { return strings.Join(l, "\n") + "\n" }
+	if len(lines) != 3 {
+		t.Fatalf("в журнале %d строк, ожидалось 3", len(lines))
+	}
+	// rehash — строка 2 с другим Details и честно пересчитанным хешем
+	var e auditEntry
+	json.Unmarshal([]byte(lines[1]), &e)
+	e.Details = "подмена"
+	e.Hash = e.computeHash()
+	rehashed, _ := json.Marshal(e)
+
+	tests := []struct {
+		name    string
+		log     string
+		wantErr string // "" — цепочка цела
+	}{
+		{"целый", join(lines[0], lines[1], lines[2]), ""},
+		{"пустой", "", ""},
+		{"изменена строка", join(lines[0], strings.Replace(lines[1], `"details":"nginx_write"`, `"details":"nginx_remove"`, 1), lines[2]), "строка 2 (seq 2, nginx_write t): хеш не совпадает"},
+		{"удалена строка", join(lines[0], lines[2]), "строка 2: seq=3, ожидался 2"},
+		{"переставлены строки", join(lines[0], lines[2], lines[1]), "строка 2: seq=3, ожидался 2"},
+		{"пересчитан хеш", join(lines[0], string(rehashed), lines[2]), "строка 3 (seq 3): prev не совпадает"},
+		{"лишнее поле", join(lines[0], strings.Replace(lines[1], `{"seq"`, `{"x":1,"seq"`, 1), lines[2]), "строка 2 изменена"},
+		{"мусор", join(lines[0], "not json", lines[2]), "строка 2 не читается"},
+	}
+	for _, tt := range tests {
+		n, last, err := verifyAudit([]byte(tt.log))
+		switch {
+		case tt.wantErr == "" && err != nil:
+			t.Errorf("%s: %v", tt.name, err)
+		case tt.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tt.wantErr)):
+			t.Errorf("%s: ошибка %v, ожидалась %q", tt.name, err, tt.wantErr)
+		}
+		if tt.name == "целый" {
+			json.Unmarshal([]byte(lines[2]), &e)
+			if n != 3 || last != e.Hash {
+				t.Errorf("целый: %d записей, последний хеш %s; ожидалось 3, %s", n, last, e.Hash)
+			}
+		}
+	}
+}
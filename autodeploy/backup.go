@@ -0,0 +1,532 @@
+package main
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// ------------------------------
+// (24) Резервные копии сайтов (локально или в S3)
+// ------------------------------
+
+// Резервная копия — siteBundle (см. раздел (23)), упакованный в один tar:
+// <домен>/<метка>.tar, метка — время копии. Если задан BACKUP_INTERVAL (по
+// умолчанию 0 — только `autodeploy backup create`), копии делаются раз в
+// BACKUP_INTERVAL часов для каждого развёрнутого сайта и прореживаются по
+// схеме «дед-отец-сын»: последняя копия за каждый из BACKUP_KEEP_DAILY дней,
+// BACKUP_KEEP_WEEKLY недель и BACKUP_KEEP_MONTHLY месяцев. Копию можно
+// восстановить и на чистом сервере, подготовленном 1.go–6.go.
+
+// backupStore — где лежат копии: каталог BACKUP_DIR или бакет S3.
+type backupStore interface {
+	put(key, path string) error // загрузить файл path как key
+	get(key, path string) error // скачать key в файл path
+	list(prefix string) ([]string, error)
+	remove(key string) error
+}
+
+func newBackupStore() backupStore {
+	if BACKUP_S3_ENDPOINT != "" {
+		return &s3Store{endpoint: strings.TrimRight(BACKUP_S3_ENDPOINT, "/"), bucket: BACKUP_S3_BUCKET,
+			region: BACKUP_S3_REGION, accessKey: BACKUP_S3_ACCESS, secretKey: BACKUP_S3_SECRET}
+	}
+	return localStore{dir: BACKUP_DIR}
+}
+
+// backupTmpDir — временные файлы копий; в BACKUP_DIR, чтобы rename в
+// localStore.put не переходил между файловыми системами.
+func backupTmpDir() string {
+	return filepath.Join(BACKUP_DIR, ".tmp")
+}
+
+// backupKey — ключ копии домена с меткой label.
+func backupKey(domain, label string) string {
+	return domain + "/" + label + ".tar"
+}
+
+// parseBackupKey разбирает ключ <домен>/<метка>.tar.
+func parseBackupKey(key string) (domain, label string, t time.Time, ok bool) {
+	domain, file, found := strings.Cut(key, "/")
+	label = strings.TrimSuffix(file, ".tar")
+	if !found || label == file {
+		return "", "", time.Time{}, false
+	}
+	t, err := time.ParseInLocation("20060102-150405", label, time.Local)
+	return domain, label, t, err == nil
+}
+
+// createBackup делает копию сайта domain и прореживает старые копии.
+func createBackup(store backupStore, domain, trigger string) (string, error) {
+	unlock, err := lockDomain(domain)
+	if err != nil {
+		return "", fmt.Errorf("блокировка домена: %v", err)
+	}
+	defer unlock()
+	return createBackupLocked(store, domain, trigger)
+}
+
+// createBackupLocked — createBackup для вызывающего, который уже держит
+// блокировку домена.
+func createBackupLocked(store backupStore, domain, trigger string) (string, error) {
+	d, err := loadDeployment(domain)
+	if err != nil {
+		d = &deployment{Domain: domain, Folder: domain}
+	}
+	if d.Status == "running" || d.Status == "failed" {
+		return "", fmt.Errorf("деплой не завершён (статус %s)", d.Status)
+	}
+	if _, err := os.Stat(filepath.Join(WATCH_DIR, d.Folder)); err != nil {
+		return "", fmt.Errorf("папки сайта нет: %v", err)
+	}
+	d.Trigger = trigger
+	b := siteBundle{Domain: domain, Folder: d.Folder, Created: time.Now(), Trigger: trigger}
+	label := b.Created.Format("20060102-150405")
+	dir := filepath.Join(backupTmpDir(), domain+"-"+label)
+	defer os.RemoveAll(dir)
+	defer os.Remove(dir + ".tar")
+	if err := b.save(d, dir); err != nil {
+		return "", err
+	}
+	if err := runCmd("tar", "-cf", dir+".tar", "-C", dir, "."); err != nil {
+		return "", fmt.Errorf("tar: %v", err)
+	}
+	key := backupKey(domain, label)
+	if err := store.put(key, dir+".tar"); err != nil {
+		return "", fmt.Errorf("сохранение %s: %v", key, err)
+	}
+	pruneBackups(store, domain)
+	return key, nil
+}
+
+// pruneBackups удаляет копии domain, не нужные по схеме «дед-отец-сын».
+func pruneBackups(store backupStore, domain string) {
+	keys, err := store.list(domain + "/")
+	if err != nil {
+		log.Printf("[WARN] Копии %s: не смогли получить список для прореживания: %v", domain, err)
+		return
+	}
+	type backup struct {
+		key string
+		t   time.Time
+	}
+	var list []backup
+	for _, k := range keys {
+		if _, _, t, ok := parseBackupKey(k); ok {
+			list = append(list, backup{k, t})
+		}
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].t.After(list[j].t) })
+	times := make([]time.Time, len(list))
+	for i, b := range list {
+		times[i] = b.t
+	}
+	keep := gfsKeep(times, BACKUP_KEEP_DAILY, BACKUP_KEEP_WEEKLY, BACKUP_KEEP_MONTHLY)
+	for i, b := range list {
+		if keep[i] {
+			continue
+		}
+		err := store.remove(b.key)
+		auditRecord(auditEntry{Action: "backup_delete", Target: b.key, Domain: domain,
+			Details: "прореживание копий", Trigger: "timer:backup_retention"}, err)
+		if err != nil {
+			log.Printf("[WARN] Копии %s: не смогли удалить %s: %v", domain, b.key, err)
+		}
+	}
+}
+
+// gfsKeep отмечает, какие копии оставить. times — от новых к старым; самая
+// новая копия остаётся всегда.
+func gfsKeep(times []time.Time, daily, weekly, monthly int) map[int]bool {
+	keep := map[int]bool{}
+	if len(times) > 0 {
+		keep[0] = true
+	}
+	periods := []struct {
+		limit int
+		key   func(t time.Time) string
+	}{
+		{daily, func(t time.Time) string { return t.Format("2006-01-02") }},
+		{weekly, func(t time.Time) string {
+			y, w := t.ISOWeek()
+			return fmt.Sprintf("%d-W%02d", y, w)
+		}},
+		{monthly, func(t time.Time) string { return t.Format("2006-01") }},
+	}
+	for _, p := range periods {
+		seen := map[string]bool{}
+		for i, t := range times {
+			k := p.key(t)
+			if seen[k] {
+				continue
+			}
+			if len(seen) >= p.limit {
+				break
+			}
+			seen[k] = true
+			keep[i] = true
+		}
+	}
+	return keep
+}
+
+// backupScheduler раз в 10 минут делает копии сайтов, у которых последняя
+// копия старше BACKUP_INTERVAL часов.
+func backupScheduler() {
+	if BACKUP_INTERVAL == 0 {
+		return
+	}
+	for {
+		backupDue()
+		time.Sleep(10 * time.Minute)
+	}
+}
+
+func backupDue() {
+	store := newBackupStore()
+	keys, err := store.list("")
+	if err != nil {
+		log.Printf("[WARN] Резервные копии: хранилище недоступно: %v", err)
+		return
+	}
+	last := map[string]time.Time{}
+	for _, k := range keys {
+		if domain, _, t, ok := parseBackupKey(k); ok && t.After(last[domain]) {
+			last[domain] = t
+		}
+	}
+	for _, d := range listDeployments() {
+		if d.Status != "done" || time.Since(last[d.Domain]) < time.Duration(BACKUP_INTERVAL)*time.Hour {
+			continue
+		}
+		key, err := createBackup(store, d.Domain, "timer:backup_interval")
+		if err != nil {
+			logEvent(logEntry{Level: "ERROR", Domain: d.Domain, Step: "backup"}, "Резервная копия %s не создана: %v", d.Domain, err)
+			continue
+		}
+		logEvent(logEntry{Level: "INFO", Domain: d.Domain, Step: "backup"}, "Резервная копия %s: %s", d.Domain, key)
+	}
+}
+
+// cmdBackup — `autodeploy backup list [домен]`, `backup create <домен>|--all`,
+// `backup restore <домен> [метка]`.
+func cmdBackup(args []string) int {
+	usage := "Использование: autodeploy backup list [домен] | create <домен>|--all | restore <домен> [метка]"
+	if len(args) == 0 {
+		fmt.Fprintln(os.Stderr, usage)
+		return 2
+	}
+	// Домен в аргументах — часть ключа копии (каталога или объекта S3)
+	if len(args) > 1 && args[1] != "--all" {
+		domain, ok := domainArg(args[1])
+		if !ok {
+			return 2
+		}
+		args = append([]string{args[0], domain}, args[2:]...)
+	}
+	store := newBackupStore()
+	switch {
+	case args[0] == "list" && len(args) <= 2:
+		prefix := ""
+		if len(args) == 2 {
+			prefix = args[1] + "/"
+		}
+		keys, err := store.list(prefix)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "[ERROR]", err)
+			return 1
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			if domain, label, t, ok := parseBackupKey(k); ok {
+				fmt.Printf("%-30s %s  %s\n", domain, label, t.Format("02.01.2006 15:04"))
+			}
+		}
+		return 0
+	case args[0] == "create" && len(args) == 2:
+		domains := []string{args[1]}
+		if args[1] == "--all" {
+			domains = nil
+			for _, d := range listDeployments() {
+				if d.Status == "done" {
+					domains = append(domains, d.Domain)
+				}
+			}
+		}
+		rc := 0
+		for _, domain := range domains {
+			key, err := createBackup(store, domain, "cli:backup create")
+			if err != nil {
+				log.Printf("[ERROR] Резервная копия %s не создана: %v", domain, err)
+				rc = 1
+				continue
+			}
+			log.Printf("[INFO] Резервная копия %s: %s", domain, key)
+		}
+		return rc
+	case args[0] == "restore" && (len(args) == 2 || len(args) == 3):
+		if err := restoreBackup(store, args[1], args[2:]); err != nil {
+			log.Printf("[ERROR] Сайт %s не восстановлен: %v", args[1], err)
+			return 1
+		}
+		return 0
+	}
+	fmt.Fprintln(os.Stderr, usage)
+	return 2
+}
+
+// restoreBackup восстанавливает domain из копии с меткой label[0] (или из
+// последней копии).
+func restoreBackup(store backupStore, domain string, label []string) error {
+	keys, err := store.list(domain + "/")
+	if err != nil {
+		return err
+	}
+	sort.Strings(keys)
+	key := ""
+	for _, k := range keys {
+		if _, l, _, ok := parseBackupKey(k); ok && (len(label) == 0 || l == label[0]) {
+			key = k
+		}
+	}
+	if key == "" {
+		return fmt.Errorf("копии не найдены")
+	}
+	dir := filepath.Join(backupTmpDir(), "restore-"+strings.ReplaceAll(key, "/", "-"))
+	defer os.RemoveAll(dir)
+	defer os.Remove(dir + ".tar")
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return err
+	}
+	if err := store.get(key, dir+".tar"); err != nil {
+		return fmt.Errorf("загрузка %s: %v", key, err)
+	}
+	if err := runCmd("tar", "-xf", dir+".tar", "-C", dir); err != nil {
+		return fmt.Errorf("распаковка %s: %v", key, err)
+	}
+	data, err := os.ReadFile(filepath.Join(dir, "bundle.json"))
+	if err != nil {
+		return err
+	}
+	b := &siteBundle{dir: dir}
+	if err := json.Unmarshal(data, b); err != nil {
+		return fmt.Errorf("bundle.json: %v", err)
+	}
+	if err := b.restoreSite("cli:backup restore " + key); err != nil {
+		return err
+	}
+	log.Printf("[INFO] Сайт %s восстановлен из резервной копии %s.", domain, key)
+	return nil
+}
+
+// localStore — копии в каталоге на этом сервере.
+type localStore struct {
+	dir string
+}
+
+func (s localStore) put(key, path string) error {
+	dst := filepath.Join(s.dir, filepath.FromSlash(key))
+	if err := os.MkdirAll(filepath.Dir(dst), 0700); err != nil {
+		return err
+	}
+	return os.Rename(path, dst)
+}
+
+func (s localStore) get(key, path string) error {
+	return runCmd("cp", filepath.Join(s.dir, filepath.FromSlash(key)), path)
+}
+
+func (s localStore) list(prefix string) ([]string, error) {
+	paths, err := filepath.Glob(filepath.Join(s.dir, "*", "*.tar"))
+	var keys []string
+	for _, p := range paths {
+		key := filepath.ToSlash(strings.TrimPrefix(p, s.dir+string(filepath.Separator)))
+		if strings.HasPrefix(key, prefix) {
+			keys = append(keys, key)
+		}
+	}
+	return keys, err
+}
+
+func (s localStore) remove(key string) error {
+	return os.Remove(filepath.Join(s.dir, filepath.FromSlash(key)))
+}
+
+// s3Store — S3-совместимое хранилище (AWS S3, MinIO). Запросы в стиле
+// endpoint/bucket/key, подпись AWS Signature Version 4.
+type s3Store struct {
+	endpoint, bucket, region, accessKey, secretKey string
+}
+
+// s3Client — без общего таймаута: копии бывают большими. Зависание ловит
+// таймаут ожидания ответа.
+var s3Client = &http.Client{Transport: &http.Transport{
+	Proxy:                 http.ProxyFromEnvironment,
+	ResponseHeaderTimeout: 2 * time.Minute,
+	TLSHandshakeTimeout:   30 * time.Second,
+}}
+
+// do выполняет подписанный запрос; при ответе не 2xx возвращает ошибку с
+// кодом и текстом из XML S3.
+func (s *s3Store) do(method, key string, query url.Values, body *os.File) (*http.Response, error) {
+	path := "/" + s.bucket
+	if key != "" {
+		path += "/" + key
+	}
+	u, err := url.Parse(s.endpoint + path)
+	if err != nil {
+		return nil, err
+	}
+	u.RawQuery = strings.ReplaceAll(query.Encode(), "+", "%20")
+	payloadHash := sha256.Sum256(nil)
+	var reqBody io.Reader
+	var size int64
+	if body != nil {
+		h := sha256.New()
+		if size, err = io.Copy(h, body); err != nil {
+			return nil, err
+		}
+		if _, err := body.Seek(0, io.SeekStart); err != nil {
+			return nil, err
+		}
+		copy(payloadHash[:], h.Sum(nil))
+		reqBody = body
+	}
+	req, err := http.NewRequest(method, u.String(), reqBody)
+	if err != nil {
+		return nil, err
+	}
+	req.ContentLength = size
+	s.sign(req, hex.EncodeToString(payloadHash[:]), time.Now().UTC())
+	resp, err := s3Client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode/100 != 2 {
+		defer resp.Body.Close()
+		var e struct {
+			Code    string `xml:"Code"`
+			Message string `xml:"Message"`
+		}
+		data, _ := io.ReadAll(io.LimitReader(resp.Body, 64<<10))
+		if xml.Unmarshal(data, &e) == nil && e.Code != "" {
+			return nil, fmt.Errorf("S3 %s %s: %s: %s", method, u.Path, e.Code, e.Message)
+		}
+		return nil, fmt.Errorf("S3 %s %s: %s", method, u.Path, resp.Status)
+	}
+	return resp, nil
+}
+
+// sign добавляет заголовки подписи AWS Signature Version 4.
+func (s *s3Store) sign(req *http.Request, payloadHash string, now time.Time) {
+	amzDate := now.Format("20060102T150405Z")
+	scope := now.Format("20060102") + "/" + s.region + "/s3/aws4_request"
+	req.Header.Set("X-Amz-Date", amzDate)
+	req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+	signed := "host;x-amz-content-sha256;x-amz-date"
+	canonical := strings.Join([]string{
+		req.Method,
+		req.URL.EscapedPath(),
+		req.URL.RawQuery,
+		"host:" + req.URL.Host + "\nx-amz-content-sha256:" + payloadHash + "\nx-amz-date:" + amzDate + "\n",
+		signed,
+		payloadHash,
+	}, "\n")
+	sum := sha256.Sum256([]byte(canonical))
+	toSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + hex.EncodeToString(sum[:])
+	key := []byte("AWS4" + s.secretKey)
+	for _, part := range []string{now.Format("20060102"), s.region, "s3", "aws4_request", toSign} {
+		mac := hmac.New(sha256.New, key)
+		mac.Write([]byte(part))
+		key = mac.Sum(nil)
+	}
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		s.accessKey, scope, signed, hex.EncodeToString(key)))
+}
+
+func (s *s3Store) put(key, path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	resp, err := s.do("PUT", key, nil, f)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	return nil
+}
+
+func (s *s3Store) get(key, path string) error {
+	resp, err := s.do("GET", key, nil, nil)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0600)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(f, resp.Body); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// list — ListObjectsV2 со всеми страницами.
+func (s *s3Store) list(prefix string) ([]string, error) {
+	var keys []string
+	token := ""
+	for {
+		q := url.Values{"list-type": {"2"}, "prefix": {prefix}}
+		if token != "" {
+			q.Set("continuation-token", token)
+		}
+		resp, err := s.do("GET", "", q, nil)
+		if err != nil {
+			return nil, err
+		}
+		var page struct {
+			Contents []struct {
+				Key string `xml:"Key"`
+			} `xml:"Contents"`
+			IsTruncated           bool   `xml:"IsTruncated"`
+			NextContinuationToken string `xml:"NextContinuationToken"`
+		}
+		err = xml.NewDecoder(resp.Body).Decode(&page)
+		resp.Body.Close()
+		if err != nil {
+			return nil, fmt.Errorf("S3 список объектов: %v", err)
+		}
+		for _, c := range page.Contents {
+			keys = append(keys, c.Key)
+		}
+		if !page.IsTruncated || page.NextContinuationToken == "" {
+			return keys, nil
+		}
+		token = page.NextContinuationToken
+	}
+}
+
+func (s *s3Store) remove(key string) error {
+	resp, err := s.do("DELETE", key, nil, nil)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	return nil
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+// ------------------------------
+// Резервные копии (раздел (24)): прореживание дед-отец-сын
+// ------------------------------
+
+func TestGFSKeep(t *testing.T) {
+	at := func(s string) time.Time {
+		tm, err := time.Parse("2006-01-02 15:04", s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return tm
+	}
+	// daysBack — по копии в полдень за n дней, начиная с last, от новых к старым
+	daysBack := func(last string, n int) []time.Time {
+		var out []time.Time
+		for i := 0; i < n; i++ {
+			out = append(out, at(last+" 12:00").AddDate(0, 0, -i))
+		}
+		return out
+	}
+	tests := []struct {
+		name                   string
+		times                  []time.Time
+		daily, weekly, monthly int
+		want                   []int
+	}{
+		{"нет копий", nil, 7, 4, 6, nil},
+		{"всё выключено — остаётся новейшая", daysBack("2024-03-31", 3), 0, 0, 0, []int{0}},
+		{"несколько копий в день", []time.Time{at("2024-03-31 18:00"), at("2024-03-31 06:00"), at("2024-03-30 18:00"), at("2024-03-30 06:00"), at("2024-03-29 18:00")},
+			2, 0, 0, []int{0, 2}},
+		// 31.03.2024 — воскресенье (неделя 13), 24.03 — неделя 12; 29.02 — февраль
+		{"дни, недели, месяцы", daysBack("2024-03-31", 60), 3, 2, 2, []int{0, 1, 2, 7, 31}},
+		{"копий меньше лимитов", daysBack("2024-03-31", 2), 7, 4, 6, []int{0, 1}},
+		// 30.12.2024 — понедельник первой недели 2025 года по ISO
+		{"неделя на стыке лет", []time.Time{at("2025-01-02 12:00"), at("2024-12-30 12:00"), at("2024-12-29 12:00")},
+			0, 2, 0, []int{0, 2}},
+		{"месяцы пересекаются с днями", daysBack("2024-04-01", 3), 2, 0, 2, []int{0, 1}},
+	}
+	for _, tt := range tests {
+		keep := gfsKeep(tt.times, tt.daily, tt.weekly, tt.monthly)
+		var got []int
+		for i := range tt.times {
+			if keep[i] {
+				got = append(got, i)
+			}
+		}
+		if fmt.Sprint(got) != fmt.Sprint(tt.want) {
+			t.Errorf("%s: оставлены %v, ожидалось %v", tt.name, got, tt.want)
+		}
+	}
+}
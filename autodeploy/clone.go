@@ -0,0 +1,266 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// ------------------------------
+// (26) Команда `autodeploy clone <домен> <копия>` (копия для тестов)
+// ------------------------------
+
+// Клон, как и переименование, — деплой нового домена со своими шагами
+// (cloneSteps) и журналом с ClonedFrom = исходный домен. Исходный сайт не
+// меняется. У копии своя база и пользователь с новым паролем, адреса в базе
+// переписаны на новый домен, в nginx — X-Robots-Tag: noindex и, если задано,
+// basic auth. При ошибке откат удаляет всё, что успел создать клон.
+
+// htpasswdPath — файл basic auth домена для nginx.
+func htpasswdPath(domain string) string {
+	return filepath.Join("/etc/nginx/htpasswd", domain)
+}
+
+// cmdClone запускает клонирование сайта.
+func cmdClone(args []string) int {
+	fs := flag.NewFlagSet("clone", flag.ContinueOnError)
+	auth := fs.String("auth", "", "basic auth для копии: пользователь или пользователь:пароль (без пароля — сгенерировать)")
+	fs.Usage = func() {
+		fmt.Fprintln(os.Stderr, "Использование: autodeploy clone [-auth пользователь[:пароль]] <домен> <домен копии>")
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	if fs.NArg() != 2 {
+		fs.Usage()
+		return 2
+	}
+	srcDomain, ok := domainArg(fs.Arg(0))
+	if !ok {
+		return 2
+	}
+	domain, ok := domainArg(fs.Arg(1))
+	if !ok {
+		return 2
+	}
+	if domain == srcDomain {
+		fmt.Fprintf(os.Stderr, "[ERROR] Копия %s не может называться так же, как исходный сайт\n", fs.Arg(1))
+		return 2
+	}
+	first, second := srcDomain, domain
+	if second < first {
+		first, second = second, first
+	}
+	for _, name := range []string{first, second} {
+		unlock, err := lockDomain(name)
+		if err != nil {
+			log.Printf("[ERROR] Не смогли заблокировать домен %s: %v", name, err)
+			return 1
+		}
+		defer unlock()
+	}
+	src, err := loadDeployment(srcDomain)
+	if err != nil {
+		log.Printf("[ERROR] Нет журнала деплоя для %s: %v", srcDomain, err)
+		return 1
+	}
+	if src.Status != "done" {
+		log.Printf("[ERROR] Деплой %s не завершён (статус %s), клонировать нельзя.", srcDomain, src.Status)
+		return 1
+	}
+	if d, err := loadDeployment(domain); err == nil {
+		log.Printf("[ERROR] У %s уже есть журнал деплоя (статус %s). Незавершённый клон продолжает autodeploy retry %s.",
+			domain, d.Status, domain)
+		return 1
+	}
+	free := &siteBundle{Domain: domain}
+	if err := free.checkFree(); err != nil {
+		log.Printf("[ERROR] Не клонируем %s в %s: %v", srcDomain, domain, err)
+		return 1
+	}
+	d := &deployment{
+		Domain:     domain,
+		Idx:        src.Idx,
+		SiteType:   src.SiteType,
+		SSLNeeded:  src.SSLNeeded,
+		UseWww:     "no",
+		PHPVersion: src.PHPVersion,
+		ClonedFrom: srcDomain,
+		Staging:    true,
+		Started:    time.Now(),
+		Trigger:    "cli:clone " + srcDomain,
+	}
+	password := ""
+	if *auth != "" {
+		user, pass, _ := strings.Cut(*auth, ":")
+		if pass == "" {
+			var err error
+			if pass, err = randomPassword(12); err != nil {
+				log.Printf("[ERROR] Не смогли сгенерировать пароль basic auth: %v", err)
+				return 1
+			}
+			password = pass
+		}
+		hash, err := htpasswdHash(pass)
+		if err != nil || user == "" || strings.Contains(user, ":") {
+			log.Printf("[ERROR] Не смогли подготовить basic auth для %q: %v", user, err)
+			return 1
+		}
+		d.BasicAuth = user + ":" + hash
+	}
+	log.Printf("[INFO] Клонируем сайт %s -> %s...", srcDomain, domain)
+	if !runDeployment(d) {
+		return 1
+	}
+	if password != "" {
+		fmt.Printf("Basic auth для https://%s: %s / %s\n", domain, strings.SplitN(d.BasicAuth, ":", 2)[0], password)
+	}
+	return 0
+}
+
+// cloneSteps — шаги клонирования d.ClonedFrom -> d.Domain.
+func cloneSteps(d *deployment) []deployStep {
+	steps := []deployStep{
+		{"cloudflare", stepCloudflareCheck},
+		{"cf_ssl_flexible", stepCFSSLFlexible},
+		{"stub", stepStub},
+	}
+	if d.SSLNeeded == "yes" {
+		steps = append(steps, deployStep{"certbot", stepCertbot})
+	}
+	steps = append(steps, deployStep{"copy_site", stepCopySite})
+	if d.SiteType == "wp" {
+		steps = append(steps,
+			deployStep{"db_password", stepDBPassword},
+			deployStep{"wp_database", stepWPDatabase},
+			deployStep{"clone_db", stepCloneDB},
+			deployStep{"search_replace", stepCloneSearchReplace},
+			deployStep{"wp_noindex", stepWPNoindex},
+			deployStep{"credentials", stepCloneCredentials},
+		)
+	}
+	steps = append(steps,
+		deployStep{"permissions", stepPermissions},
+		deployStep{"basic_auth", stepBasicAuth},
+		deployStep{"final_config", stepFinalConfig},
+		deployStep{"cf_ssl_final", stepCFSSLFinal},
+		deployStep{"cf_defaults", stepCFDefaults},
+	)
+	return steps
+}
+
+// Копия файлов исходного сайта (cp -a сохраняет права и владельца). До этого
+// шага у клона нет папки: файл статуса не должен попасть в папку исходного сайта.
+func stepCopySite(d *deployment) error {
+	src := filepath.Join(WATCH_DIR, d.ClonedFrom)
+	if d.Folder == d.Domain {
+		return nil
+	}
+	// Остаток копии, прерванной падением демона
+	os.RemoveAll(d.webroot())
+	d.addUndo("remove_files", d.webroot(), "")
+	err := d.run("cp", "-a", src, d.webroot())
+	d.audit("files_copy", d.webroot(), "из "+src, err)
+	if err != nil {
+		return fmt.Errorf("копия файлов сайта: %v", err)
+	}
+	d.Folder = d.Domain
+	return nil
+}
+
+// Новый пароль пользователя MySQL копии
+func stepDBPassword(d *deployment) error {
+	var err error
+	if d.DBPass, err = randomPassword(9); err != nil {
+		return fmt.Errorf("пароль базы данных: %v", err)
+	}
+	return nil
+}
+
+// Данные исходной базы — в базу копии (её создал шаг wp_database), wp-config.php — на неё
+func stepCloneDB(d *deployment) error {
+	dump := filepath.Join(SNAPSHOT_DIR, d.Domain+".sql")
+	defer os.Remove(dump)
+	if err := os.MkdirAll(SNAPSHOT_DIR, 0700); err != nil {
+		return err
+	}
+	srcDB, _, err := siteDatabase(d.ClonedFrom)
+	if err != nil {
+		return newDeployError(ErrMySQL, "реестр сайтов: %v", err)
+	}
+	err = d.run("mysqldump", "-u", "root", "--single-transaction", "--routines", "--triggers",
+		"--result-file="+dump, srcDB)
+	if err == nil {
+		err = d.run("mysql", "-u", "root", d.DBName, "-e", "source "+dump)
+	}
+	d.audit("db_copy", d.DBName, "из базы "+srcDB, err)
+	if err != nil {
+		return newDeployError(ErrMySQL, "копия базы %s: %v", srcDB, err)
+	}
+	for _, kv := range [][2]string{{"DB_NAME", d.DBName}, {"DB_USER", d.DBUser}, {"DB_PASSWORD", d.DBPass}} {
+		if err := d.run("wp", "config", "set", kv[0], kv[1], "--path="+d.webroot(), "--allow-root"); err != nil {
+			return newDeployError(ErrWPCLI, "wp config set %s: %v", kv[0], err)
+		}
+	}
+	return nil
+}
+
+// Адреса исходного сайта (с www и без) -> адрес копии. Откат не нужен: базу
+// копии удалит откат шага wp_database.
+func stepCloneSearchReplace(d *deployment) error {
+	err := searchReplaceURLs(d, "//www."+d.ClonedFrom, "//"+d.Domain, "//"+d.ClonedFrom, "//"+d.Domain)
+	d.audit("db_search_replace", d.Domain, "//"+d.ClonedFrom+" -> //"+d.Domain, err)
+	return err
+}
+
+// «Попросить поисковые системы не индексировать сайт»
+func stepWPNoindex(d *deployment) error {
+	if err := d.run("wp", "option", "update", "blog_public", "0", "--path="+d.webroot(), "--allow-root"); err != nil {
+		return newDeployError(ErrWPCLI, "wp option update blog_public: %v", err)
+	}
+	return nil
+}
+
+// Реквизиты копии в WP_LOG: администратор тот же, что у исходного сайта
+// (база скопирована), база и пароль — свои
+func stepCloneCredentials(d *deployment) error {
+	adminUser, adminPass := d.ClonedFrom, ""
+	for _, line := range strings.Split(credentialLines(d.ClonedFrom), "\n") {
+		if f := strings.Split(line, "|"); len(f) == 5 {
+			adminUser, adminPass = f[1], f[2]
+		}
+	}
+	fwp, err := os.OpenFile(WP_LOG, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return err
+	}
+	defer fwp.Close()
+	line := fmt.Sprintf("%s|%s|%s|%s|%s\n", d.Domain, adminUser, adminPass, d.DBName, d.DBPass)
+	d.addUndo("remove_line", WP_LOG, line)
+	_, err = fwp.WriteString(line)
+	return err
+}
+
+// Файл basic auth для nginx (если клон запрошен с -auth)
+func stepBasicAuth(d *deployment) error {
+	if d.BasicAuth == "" {
+		return nil
+	}
+	path := htpasswdPath(d.Domain)
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	d.addUndo("remove_file", path, "")
+	err := os.WriteFile(path, []byte(d.BasicAuth+"\n"), 0640)
+	if err == nil {
+		// nginx читает файл от www-data
+		runCmd("chown", "root:www-data", path)
+	}
+	d.audit("htpasswd_write", path, "basic auth для "+strings.SplitN(d.BasicAuth, ":", 2)[0], err)
+	return err
+}
@@ -0,0 +1,399 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ------------------------------
+// (31) Проверка cloudflare.txt (не пуст?)
+// ------------------------------
+func checkCloudflareFileSimple() bool {
+	fi, err := os.Stat(CLOUDFLARE_TXT)
+	if err != nil {
+		log.Println("[ERROR]", CLOUDFLARE_TXT, "не существует! (Ошибка 550)")
+		return false
+	}
+	if fi.Size() == 0 {
+		log.Println("[ERROR]", CLOUDFLARE_TXT, "пуст! (Ошибка 550)")
+		return false
+	}
+	log.Println("[INFO] cloudflare.txt не пуст, продолжаем...")
+	return true
+}
+
+// ------------------------------
+// (32) Проверка домена в Cloudflare (ZONE_WAIT_ATTEMPTS попыток по ZONE_WAIT_SECONDS сек)
+//     Возвращает аккаунт или deployError с причиной
+// ------------------------------
+
+// cfAccount — аккаунт и зона Cloudflare, в которых найден домен.
+type cfAccount struct {
+	ZoneID   string
+	ZoneName string // зона может быть родительской: shop.example.com -> example.com
+	Email    string
+	APIKey   string
+	NoRecord bool // A-записи домена не было: создана (или будет создана при create = false)
+}
+
+// checkDomainCloudflare ищет зону домена (сам домен или родительская зона до
+// регистрируемого домена, см. zoneCandidates) и проверяет, что DNS-запись
+// домена указывает на этот сервер. Если записи нет и create = true, создаёт
+// проксируемую A-запись на SERVER_IP; при create = false (сухой прогон)
+// отсутствие записи не считается ошибкой.
+func checkDomainCloudflare(domain string, create bool) (cfAccount, error) {
+	log.Println("[INFO] Проверяем домен", domain, "в Cloudflare...")
+	data, err := os.ReadFile(CLOUDFLARE_TXT)
+	if err != nil {
+		log.Printf("[ERROR] Невозможно прочитать %s: %v", CLOUDFLARE_TXT, err)
+		return cfAccount{}, newDeployError(ErrCFAPI, "невозможно прочитать %s: %v", CLOUDFLARE_TXT, err)
+	}
+	candidates := zoneCandidates(domain)
+	if len(candidates) == 0 {
+		return cfAccount{}, newDeployError(ErrCFZoneNotFound, "%s — публичный суффикс, а не домен", domain)
+	}
+	// Если не подошёл ни один аккаунт, сообщаем о самой «близкой к успеху»
+	// неудаче: DNS не совпал > зона не активна > ошибка API > зоны нет
+	failure := newDeployError(ErrCFZoneNotFound, "зона %s не найдена ни в одном аккаунте %s",
+		strings.Join(candidates, " / "), CLOUDFLARE_TXT)
+	rank := map[*deployErrCode]int{ErrCFZoneNotFound: 0, ErrCFAPI: 1, ErrCFZoneInactive: 2, ErrDNSMismatch: 3}
+	fail := func(err error) {
+		if rank[errorCodeOf(err)] > rank[errorCodeOf(failure)] {
+			failure = err
+		}
+	}
+	lines := strings.Split(string(data), "\n")
+	for _, line := range lines {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		parts := strings.Split(line, "|")
+		if len(parts) < 2 {
+			continue
+		}
+		acc := cfAccount{Email: parts[0], APIKey: parts[1]}
+		cf := acc.client()
+		// Самая узкая зона выигрывает: shop.example.com может быть отдельной зоной
+		var zone cfZone
+		for _, cand := range candidates {
+			z, ok, err := cf.zone(cand)
+			if err != nil {
+				log.Println("[WARN] Ошибка API Cloudflare:", err)
+				fail(newDeployError(ErrCFAPI, "запрос зоны %s (%s): %v", cand, acc.Email, err))
+				break
+			}
+			if ok {
+				zone = z
+				break
+			}
+		}
+		if zone.ID == "" {
+			continue
+		}
+		log.Printf("[INFO] Найден ZONE_ID=%s (зона %s), статус=%s", zone.ID, zone.Name, zone.Status)
+		attempts := 0
+		for zone.Status != "active" && attempts < ZONE_WAIT_ATTEMPTS {
+			log.Printf("[INFO] Ждём %d сек, чтобы зона стала active...", ZONE_WAIT_SECONDS)
+			time.Sleep(time.Duration(ZONE_WAIT_SECONDS) * time.Second)
+			if z, ok, err := cf.zone(zone.Name); err != nil {
+				log.Println("[WARN] Ошибка API Cloudflare:", err)
+			} else if ok {
+				zone.Status = z.Status
+			}
+			attempts++
+		}
+		if zone.Status != "active" {
+			fail(newDeployError(ErrCFZoneInactive, "зона %s в статусе %q после %d проверок", zone.Name, zone.Status, attempts))
+			continue
+		}
+		acc.ZoneID, acc.ZoneName = zone.ID, zone.Name
+		records, err := cf.dnsRecords(zone.ID, domain)
+		if err != nil {
+			log.Println("[WARN] Ошибка API Cloudflare (DNS):", err)
+			fail(newDeployError(ErrCFAPI, "запрос DNS-записей %s: %v", domain, err))
+			continue
+		}
+		if len(records) == 0 {
+			acc.NoRecord = true
+			if !create {
+				log.Printf("[INFO] DNS-записи %s нет, деплой создаст A-запись на %s", domain, SERVER_IP)
+				return acc, nil
+			}
+			if err := createDNSRecord(acc, domain); err != nil {
+				fail(err)
+				continue
+			}
+			return acc, nil
+		}
+		var found []string
+		for _, r := range records {
+			if r.Name == domain && r.Content == SERVER_IP {
+				log.Printf("[INFO] DNS=%s совпадает с %s", r.Content, SERVER_IP)
+				return acc, nil
+			}
+			found = append(found, r.Type+" "+r.Content)
+		}
+		fail(newDeployError(ErrDNSMismatch, "DNS-записи %s: %s, ожидался IP сервера %s", domain, strings.Join(found, ", "), SERVER_IP))
+	}
+	log.Printf("[ERROR] Не нашли активную зону для %s c IP=%s", domain, SERVER_IP)
+	return cfAccount{}, failure
+}
+
+// createDNSRecord создаёт в зоне acc проксируемую A-запись name -> SERVER_IP.
+func createDNSRecord(acc cfAccount, name string) error {
+	log.Printf("[INFO] Создаём A-запись %s -> %s в зоне %s...", name, SERVER_IP, acc.ZoneName)
+	err := acc.client().createDNSRecord(acc.ZoneID, cfDNSRecord{Type: "A", Name: name, Content: SERVER_IP, TTL: 1, Proxied: true})
+	if err != nil {
+		return newDeployError(ErrCFAPI, "создание A-записи %s: %v", name, err)
+	}
+	return nil
+}
+
+// ------------------------------
+// (33) set_cf_ssl_mode (flexible|full), sleep 5
+// ------------------------------
+// Возвращает ошибку, если Cloudflare не подтвердил изменение.
+func setCFSSLMode(acc cfAccount, mode string) error {
+	log.Printf("[INFO] Ставим SSL=%s (zone=%s)...", mode, acc.ZoneID)
+	err := acc.client().setSetting(acc.ZoneID, "ssl", mode)
+	if err == nil {
+		log.Printf("[INFO] ssl=%s -> success", mode)
+	} else {
+		log.Printf("[WARN] set_cf_ssl_mode(%s) ошибка: %v", mode, err)
+	}
+	sleepSec(0)
+	return err
+}
+
+// ------------------------------
+// (34) apply_default_cf_settings
+// ------------------------------
+// done вызывается после каждой настройки (err == nil — Cloudflare подтвердил).
+func applyDefaultCFSettings(acc cfAccount, done func(st cfSetting, err error)) {
+	cf := acc.client()
+	log.Println("[INFO] Применяем дефолтные настройки CF в новом порядке...")
+	for _, st := range cfDefaultSettings {
+		err := cf.setSetting(acc.ZoneID, st.Key, st.Value)
+		if err == nil {
+			log.Printf("[INFO] %s=%s -> success", st.Key, st.Value)
+		} else {
+			log.Printf("[WARN] patchSetting(%s=%s) ошибка: %v", st.Key, st.Value, err)
+		}
+		sleepSec(0)
+		done(st, err)
+	}
+}
+
+// cfDefaultSettings — настройки зоны, которые выставляются после деплоя
+// (по порядку). Задаются конфигом, ключ cloudflare_settings.
+var cfDefaultSettings []cfSetting
+
+type cfSetting struct {
+	Key   string `json:"key"`
+	Value string `json:"value"`
+}
+
+// ------------------------------
+// (35) Клиент API Cloudflare
+// ------------------------------
+
+// Запросы к API Cloudflare v4 по адресу CLOUDFLARE_API_URL с авторизацией
+// e-mail + Global API Key из CLOUDFLARE_TXT. Ответ API — обёртка
+// {success, errors[], result, result_info}: при success = false или статусе
+// не 2xx возвращается cfError с сообщениями из errors[]. Списки (зоны,
+// DNS-записи) читаются постранично.
+
+// cfPerPage — размер страницы списков (для зон API разрешает не больше 50).
+const cfPerPage = 50
+
+// cfClient — клиент API Cloudflare одного аккаунта.
+type cfClient struct {
+	email, apiKey string
+}
+
+// client — клиент API для аккаунта acc.
+func (acc cfAccount) client() *cfClient {
+	return &cfClient{email: acc.Email, apiKey: acc.APIKey}
+}
+
+// cfZone — зона Cloudflare.
+type cfZone struct {
+	ID     string `json:"id"`
+	Name   string `json:"name"`
+	Status string `json:"status"` // active, pending, …
+}
+
+// cfDNSRecord — DNS-запись зоны.
+type cfDNSRecord struct {
+	ID      string `json:"id,omitempty"`
+	Type    string `json:"type"`
+	Name    string `json:"name"`
+	Content string `json:"content"`
+	TTL     int    `json:"ttl"` // 1 — автоматически
+	Proxied bool   `json:"proxied"`
+}
+
+// cfAPIError — элемент errors[] ответа API.
+type cfAPIError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+// cfError — API ответило ошибкой.
+type cfError struct {
+	Method string
+	Path   string
+	Status int
+	Errors []cfAPIError
+}
+
+func (e *cfError) Error() string {
+	var msgs []string
+	for _, ae := range e.Errors {
+		msgs = append(msgs, fmt.Sprintf("%s (код %d)", ae.Message, ae.Code))
+	}
+	if len(msgs) == 0 {
+		msgs = append(msgs, "success=false без описания ошибки")
+	}
+	return fmt.Sprintf("Cloudflare %s %s: HTTP %d: %s", e.Method, e.Path, e.Status, strings.Join(msgs, "; "))
+}
+
+// cfResponse — общая обёртка ответов API.
+type cfResponse struct {
+	Success    bool            `json:"success"`
+	Errors     []cfAPIError    `json:"errors"`
+	Result     json.RawMessage `json:"result"`
+	ResultInfo *struct {
+		Page       int `json:"page"`
+		TotalPages int `json:"total_pages"`
+	} `json:"result_info"`
+}
+
+// do выполняет запрос к path (от CLOUDFLARE_API_URL), body отправляется как
+// JSON. endpoint — метка запроса в метриках: zones, dns_records или settings.
+func (c *cfClient) do(endpoint, method, path string, query url.Values, body interface{}) (*cfResponse, error) {
+	resp, err := c.request(method, path, query, body)
+	metrics.cfCall(endpoint, err == nil)
+	return resp, err
+}
+
+func (c *cfClient) request(method, path string, query url.Values, body interface{}) (*cfResponse, error) {
+	u := strings.TrimRight(CLOUDFLARE_API_URL, "/") + path
+	if len(query) > 0 {
+		u += "?" + query.Encode()
+	}
+	var reqBody io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return nil, err
+		}
+		reqBody = bytes.NewReader(data)
+	}
+	req, err := http.NewRequest(method, u, reqBody)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "autodeploy")
+	req.Header.Set("X-Auth-Email", c.email)
+	req.Header.Set("X-Auth-Key", c.apiKey)
+	client := &http.Client{Timeout: time.Duration(CLOUDFLARE_TIMEOUT) * time.Second}
+	resp, err := client.Do(req)
+	if err != nil {
+		var ue *url.Error
+		if errors.As(err, &ue) {
+			err = ue.Err // адрес и так в тексте ошибки
+		}
+		return nil, fmt.Errorf("Cloudflare %s %s: %v", method, path, err)
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(io.LimitReader(resp.Body, 16<<20))
+	if err != nil {
+		return nil, fmt.Errorf("Cloudflare %s %s: %v", method, path, err)
+	}
+	var r cfResponse
+	if err := json.Unmarshal(data, &r); err != nil {
+		return nil, fmt.Errorf("Cloudflare %s %s: %s, ответ не JSON: %.200q", method, path, resp.Status, data)
+	}
+	if !r.Success || resp.StatusCode/100 != 2 {
+		return nil, &cfError{Method: method, Path: path, Status: resp.StatusCode, Errors: r.Errors}
+	}
+	return &r, nil
+}
+
+// list читает все страницы списка path; add получает result каждой страницы.
+func (c *cfClient) list(endpoint, path string, query url.Values, add func(result json.RawMessage) error) error {
+	for page := 1; ; page++ {
+		q := url.Values{"page": {strconv.Itoa(page)}, "per_page": {strconv.Itoa(cfPerPage)}}
+		for k, v := range query {
+			q[k] = v
+		}
+		resp, err := c.do(endpoint, "GET", path, q, nil)
+		if err != nil {
+			return err
+		}
+		if err := add(resp.Result); err != nil {
+			return fmt.Errorf("Cloudflare GET %s: разбор ответа: %v", path, err)
+		}
+		if resp.ResultInfo == nil || page >= resp.ResultInfo.TotalPages {
+			return nil
+		}
+	}
+}
+
+// zone ищет зону с именем name; ok = false — в аккаунте её нет.
+func (c *cfClient) zone(name string) (zone cfZone, ok bool, err error) {
+	var zones []cfZone
+	err = c.list("zones", "/zones", url.Values{"name": {name}}, func(result json.RawMessage) error {
+		var page []cfZone
+		err := json.Unmarshal(result, &page)
+		zones = append(zones, page...)
+		return err
+	})
+	if err != nil {
+		return cfZone{}, false, err
+	}
+	for _, z := range zones {
+		if z.Name == name {
+			return z, true, nil
+		}
+	}
+	return cfZone{}, false, nil
+}
+
+// dnsRecords — DNS-записи зоны zoneID с именем name (всех типов).
+func (c *cfClient) dnsRecords(zoneID, name string) ([]cfDNSRecord, error) {
+	var records []cfDNSRecord
+	err := c.list("dns_records", "/zones/"+url.PathEscape(zoneID)+"/dns_records", url.Values{"name": {name}}, func(result json.RawMessage) error {
+		var page []cfDNSRecord
+		err := json.Unmarshal(result, &page)
+		records = append(records, page...)
+		return err
+	})
+	return records, err
+}
+
+// createDNSRecord создаёт запись rec в зоне zoneID.
+func (c *cfClient) createDNSRecord(zoneID string, rec cfDNSRecord) error {
+	_, err := c.do("dns_records", "POST", "/zones/"+url.PathEscape(zoneID)+"/dns_records", nil, rec)
+	return err
+}
+
+// setSetting меняет настройку зоны key (ssl, always_use_https, …) на value.
+func (c *cfClient) setSetting(zoneID, key, value string) error {
+	_, err := c.do("settings", "PATCH", "/zones/"+url.PathEscape(zoneID)+"/settings/"+url.PathEscape(key), nil,
+		map[string]string{"id": key, "value": value})
+	return err
+}
@@ -0,0 +1,176 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// ------------------------------
+// Клиент API Cloudflare (раздел (35)) на локальном httptest-сервере
+// ------------------------------
+
+// cfTestServer поднимает заглушку API и направляет на неё клиент.
+func cfTestServer(t *testing.T, handler http.HandlerFunc) *cfClient {
+	t.Helper()
+	srv := httptest.NewServer(handler)
+	oldURL, oldTimeout := CLOUDFLARE_API_URL, CLOUDFLARE_TIMEOUT
+	CLOUDFLARE_API_URL, CLOUDFLARE_TIMEOUT = srv.URL+"/client/v4", 5
+	t.Cleanup(func() {
+		srv.Close()
+		CLOUDFLARE_API_URL, CLOUDFLARE_TIMEOUT = oldURL, oldTimeout
+	})
+	return cfAccount{Email: "admin@example.com", APIKey: "secret"}.client()
+}
+
+// cfWrite отвечает обёрткой API с результатом result.
+func cfWrite(w http.ResponseWriter, status int, success bool, errs []cfAPIError, result interface{}, page, totalPages int) {
+	resp := map[string]interface{}{"success": success, "errors": errs, "result": result}
+	if totalPages > 0 {
+		resp["result_info"] = map[string]int{"page": page, "total_pages": totalPages}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(resp)
+}
+
+func TestCFZonePagination(t *testing.T) {
+	var pages []string
+	c := cfTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/client/v4/zones" {
+			t.Errorf("путь %s", r.URL.Path)
+		}
+		if r.Header.Get("X-Auth-Email") != "admin@example.com" || r.Header.Get("X-Auth-Key") != "secret" {
+			t.Errorf("нет авторизации: %v", r.Header)
+		}
+		q := r.URL.Query()
+		if q.Get("name") != "example.com" || q.Get("per_page") != "50" {
+			t.Errorf("запрос %s", r.URL.RawQuery)
+		}
+		pages = append(pages, q.Get("page"))
+		// Первая страница — зона с похожим именем, нужная — только на второй
+		switch q.Get("page") {
+		case "1":
+			cfWrite(w, 200, true, nil, []cfZone{{ID: "z1", Name: "sub.example.com", Status: "active"}}, 1, 2)
+		default:
+			cfWrite(w, 200, true, nil, []cfZone{{ID: "z2", Name: "example.com", Status: "active"}}, 2, 2)
+		}
+	})
+	zone, ok, err := c.zone("example.com")
+	if err != nil || !ok {
+		t.Fatalf("zone: %v, ok=%v", err, ok)
+	}
+	if zone.ID != "z2" {
+		t.Errorf("зона %+v, ожидалась z2", zone)
+	}
+	if strings.Join(pages, ",") != "1,2" {
+		t.Errorf("страницы %v, ожидались 1,2", pages)
+	}
+}
+
+func TestCFDNSRecordsPagination(t *testing.T) {
+	c := cfTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/client/v4/zones/z1/dns_records" {
+			t.Errorf("путь %s", r.URL.Path)
+		}
+		var page int
+		fmt.Sscan(r.URL.Query().Get("page"), &page)
+		rec := cfDNSRecord{ID: fmt.Sprint("r", page), Type: "A", Name: "example.com", Content: "192.0.2.1", TTL: 1}
+		cfWrite(w, 200, true, nil, []cfDNSRecord{rec}, page, 3)
+	})
+	records, err := c.dnsRecords("z1", "example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var ids []string
+	for _, rec := range records {
+		ids = append(ids, rec.ID)
+	}
+	if strings.Join(ids, ",") != "r1,r2,r3" {
+		t.Errorf("записи %v, ожидались r1,r2,r3", ids)
+	}
+}
+
+func TestCFZoneNotFound(t *testing.T) {
+	c := cfTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+		cfWrite(w, 200, true, nil, []cfZone{}, 1, 0)
+	})
+	if _, ok, err := c.zone("example.com"); err != nil || ok {
+		t.Errorf("zone: %v, ok=%v, ожидалось «нет зоны»", err, ok)
+	}
+}
+
+func TestCFSuccessFalse(t *testing.T) {
+	c := cfTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+		cfWrite(w, 200, false, []cfAPIError{{Code: 1004, Message: "DNS Validation Error"}}, nil, 0, 0)
+	})
+	err := c.createDNSRecord("z1", cfDNSRecord{Type: "A", Name: "example.com", Content: "192.0.2.1", TTL: 1})
+	var ce *cfError
+	if !errors.As(err, &ce) {
+		t.Fatalf("ошибка %v, ожидалась *cfError", err)
+	}
+	if ce.Method != "POST" || ce.Path != "/zones/z1/dns_records" || ce.Status != 200 {
+		t.Errorf("cfError %+v", ce)
+	}
+	if len(ce.Errors) != 1 || ce.Errors[0].Code != 1004 {
+		t.Errorf("errors[] %+v", ce.Errors)
+	}
+	if !strings.Contains(err.Error(), "DNS Validation Error (код 1004)") {
+		t.Errorf("текст ошибки %q", err)
+	}
+}
+
+func TestCFNon2xx(t *testing.T) {
+	tests := []struct {
+		name   string
+		status int
+		body   string
+		want   string
+	}{
+		{"403 с errors[]", 403, `{"success":false,"errors":[{"code":9103,"message":"Unknown X-Auth-Key or X-Auth-Email"}],"result":null}`,
+			"HTTP 403: Unknown X-Auth-Key or X-Auth-Email (код 9103)"},
+		{"500 с success=true", 500, `{"success":true,"errors":[],"result":{}}`,
+			"Cloudflare PATCH /zones/z1/settings/ssl: HTTP 500"},
+		{"502 не JSON", 502, `<html><body>Bad gateway</body></html>`,
+			"502 Bad Gateway, ответ не JSON"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := cfTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(tt.status)
+				io.WriteString(w, tt.body)
+			})
+			err := c.setSetting("z1", "ssl", "full")
+			if err == nil {
+				t.Fatal("ошибки нет")
+			}
+			if !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("ошибка %q, ожидалось %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestCFSetSetting(t *testing.T) {
+	c := cfTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "PATCH" || r.URL.Path != "/client/v4/zones/z1/settings/always_use_https" {
+			t.Errorf("запрос %s %s", r.Method, r.URL.Path)
+		}
+		var body map[string]string
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			t.Error(err)
+		}
+		if body["id"] != "always_use_https" || body["value"] != "on" {
+			t.Errorf("тело %v", body)
+		}
+		cfWrite(w, 200, true, nil, body, 0, 0)
+	})
+	if err := c.setSetting("z1", "always_use_https", "on"); err != nil {
+		t.Fatal(err)
+	}
+}
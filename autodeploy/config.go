@@ -0,0 +1,482 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"net"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// ------------------------------
+// (4) Конфиг /etc/autodeploy/config.yaml
+// ------------------------------
+
+// autodeployConfig — настройки демона. Файл конфига необязателен: всё, что в
+// нём не указано, берётся из defaultConfig (это значения, с которыми демон
+// работал до появления конфига). Порядок приоритета: значения по умолчанию <
+// файл < переменные окружения AUTODEPLOY_<КЛЮЧ> < флаги -<ключ>.
+// Текущие значения печатает `autodeploy config`.
+type autodeployConfig struct {
+	WatchDir          string            `json:"watch_dir"`
+	NginxAvailable    string            `json:"nginx_available"`
+	NginxEnabled      string            `json:"nginx_enabled"`
+	TplNoSSLNoWWW     string            `json:"tpl_nossl_nowww"`
+	TplNoSSLWWW       string            `json:"tpl_nossl_www"`
+	TplSSLNoWWW       string            `json:"tpl_ssl_nowww"`
+	TplSSLWWW         string            `json:"tpl_ssl_www"`
+	WPLog             string            `json:"wp_log"`
+	LogDir            string            `json:"log_dir"`
+	CloudflareTxt     string            `json:"cloudflare_txt"`
+	CloudflareAPIURL  string            `json:"cloudflare_api_url"`
+	CloudflareTimeout int               `json:"cloudflare_timeout"`
+	JournalDir        string            `json:"journal_dir"`
+	StatusDir         string            `json:"status_dir"`
+	SnapshotDir       string            `json:"snapshot_dir"`
+	Workers           int               `json:"workers"`
+	DefaultPHPVersion string            `json:"default_php_version"`
+	LogRetentionDays  int               `json:"log_retention_days"`
+	ZoneWaitAttempts  int               `json:"zone_wait_attempts"`
+	ZoneWaitSeconds   int               `json:"zone_wait_seconds"`
+	TextCheckAttempts int               `json:"text_check_attempts"`
+	TextCheckSeconds  int               `json:"text_check_seconds"`
+	HooksDir          string            `json:"hooks_dir"`
+	HookTimeout       int               `json:"hook_timeout"`
+	Webhooks          []webhookEndpoint `json:"webhooks"`
+	OutboxDir         string            `json:"outbox_dir"`
+	WebhookTimeout    int               `json:"webhook_timeout"`
+	WebhookAttempts   int               `json:"webhook_attempts"`
+	MetricsListen     string            `json:"metrics_listen"`
+	LogFormat         string            `json:"log_format"`
+	LogMaxSizeMB      int               `json:"log_max_size_mb"`
+	LogCleanInterval  int               `json:"log_clean_interval"`
+	AuditLog          string            `json:"audit_log"`
+	TrashDir          string            `json:"trash_dir"`
+	TrashGraceDays    int               `json:"trash_grace_days"`
+	BackupDir         string            `json:"backup_dir"`
+	BackupInterval    int               `json:"backup_interval"`
+	BackupKeepDaily   int               `json:"backup_keep_daily"`
+	BackupKeepWeekly  int               `json:"backup_keep_weekly"`
+	BackupKeepMonthly int               `json:"backup_keep_monthly"`
+	BackupS3Endpoint  string            `json:"backup_s3_endpoint"`
+	BackupS3Bucket    string            `json:"backup_s3_bucket"`
+	BackupS3Region    string            `json:"backup_s3_region"`
+	BackupS3AccessKey string            `json:"backup_s3_access_key"`
+	BackupS3SecretKey string            `json:"backup_s3_secret_key"`
+	MySQLSocket       string            `json:"mysql_socket"`
+	MySQLTimeout      int               `json:"mysql_timeout"`
+	SitesFile         string            `json:"sites_file"`
+	CFSettings        []cfSetting       `json:"cloudflare_settings"`
+}
+
+func defaultConfig() *autodeployConfig {
+	return &autodeployConfig{
+		WatchDir:          "/var/www",
+		NginxAvailable:    "/etc/nginx/sites-available",
+		NginxEnabled:      "/etc/nginx/sites-enabled",
+		TplNoSSLNoWWW:     "/root/auto_deploy/templates/nossl_nowww.conf.j2",
+		TplNoSSLWWW:       "/root/auto_deploy/templates/nossl_www.conf.j2",
+		TplSSLNoWWW:       "/root/auto_deploy/templates/ssl_nowww.conf.j2",
+		TplSSLWWW:         "/root/auto_deploy/templates/ssl_www.conf.j2",
+		WPLog:             "/root/auto_deploy/deploy_wp.txt",
+		LogDir:            "/root/auto_deploy/log",
+		CloudflareTxt:     "/root/auto_deploy/cloudflare.txt",
+		CloudflareAPIURL:  "https://api.cloudflare.com/client/v4",
+		CloudflareTimeout: 30,
+		JournalDir:        "/root/auto_deploy/journal",
+		StatusDir:         "/root/auto_deploy/status",
+		SnapshotDir:       "/root/auto_deploy/snapshots",
+		Workers:           4,
+		DefaultPHPVersion: "8.2",
+		LogRetentionDays:  7,
+		ZoneWaitAttempts:  3,
+		ZoneWaitSeconds:   15,
+		TextCheckAttempts: 3,
+		TextCheckSeconds:  5,
+		HooksDir:          "/etc/autodeploy/hooks",
+		HookTimeout:       300,
+		OutboxDir:         "/root/auto_deploy/outbox",
+		WebhookTimeout:    10,
+		WebhookAttempts:   20,
+		MetricsListen:     "127.0.0.1:9731",
+		LogFormat:         "text",
+		LogMaxSizeMB:      100,
+		LogCleanInterval:  60,
+		AuditLog:          "/root/auto_deploy/audit.log",
+		TrashDir:          "/root/auto_deploy/trash",
+		TrashGraceDays:    14,
+		BackupDir:         "/root/auto_deploy/backups",
+		BackupInterval:    0, // включается явно: копии занимают место и могут уходить в S3
+		BackupKeepDaily:   7,
+		BackupKeepWeekly:  4,
+		BackupKeepMonthly: 6,
+		BackupS3Region:    "us-east-1",
+		MySQLSocket:       "/run/mysqld/mysqld.sock",
+		MySQLTimeout:      300,
+		SitesFile:         "/root/auto_deploy/sites.json",
+		CFSettings: []cfSetting{
+			{"tls_1_3", "off"},
+			{"always_use_https", "off"},
+			{"0rtt", "on"},
+			{"automatic_https_rewrites", "on"},
+			{"brotli", "on"},
+			{"http3", "on"},
+			{"opportunistic_encryption", "on"},
+			{"security_level", "essentially_off"},
+			{"speed_brain", "on"},
+		},
+	}
+}
+
+// configOption — ключ конфига, который можно переопределить из окружения и
+// флагом. ptr — *string, *int или *[]cfSetting.
+type configOption struct {
+	key string
+	ptr interface{}
+	doc string
+}
+
+func (c *autodeployConfig) options() []configOption {
+	return []configOption{
+		{"watch_dir", &c.WatchDir, "каталог, куда загружают папки сайтов"},
+		{"nginx_available", &c.NginxAvailable, "каталог конфигов nginx"},
+		{"nginx_enabled", &c.NginxEnabled, "каталог симлинков включённых сайтов nginx"},
+		{"tpl_nossl_nowww", &c.TplNoSSLNoWWW, "шаблон nginx: без SSL, без www"},
+		{"tpl_nossl_www", &c.TplNoSSLWWW, "шаблон nginx: без SSL, с www"},
+		{"tpl_ssl_nowww", &c.TplSSLNoWWW, "шаблон nginx: SSL, без www"},
+		{"tpl_ssl_www", &c.TplSSLWWW, "шаблон nginx: SSL, с www"},
+		{"wp_log", &c.WPLog, "файл с реквизитами установленных WordPress"},
+		{"log_dir", &c.LogDir, "каталог логов демона"},
+		{"cloudflare_txt", &c.CloudflareTxt, "аккаунты Cloudflare, строки email|api_key"},
+		{"journal_dir", &c.JournalDir, "журналы деплоя и блокировки доменов"},
+		{"status_dir", &c.StatusDir, "копии файлов статуса деплоя"},
+		{"snapshot_dir", &c.SnapshotDir, "копии загруженных файлов на время деплоя"},
+		{"workers", &c.Workers, "сколько доменов деплоится одновременно"},
+		{"default_php_version", &c.DefaultPHPVersion, "версия PHP-FPM, если манифест её не задаёт"},
+		{"log_retention_days", &c.LogRetentionDays, "сколько дней хранить общие логи (логи доменов не удаляются)"},
+		{"log_format", &c.LogFormat, "формат лога: text или json"},
+		{"log_max_size_mb", &c.LogMaxSizeMB, "размер файла лога, после которого он ротируется; 0 — только по дням"},
+		{"log_clean_interval", &c.LogCleanInterval, "как часто удалять старые логи, минут"},
+		{"audit_log", &c.AuditLog, "журнал аудита изменяющих действий (цепочка хешей)"},
+		{"trash_dir", &c.TrashDir, "корзина: копии сайтов, удалённых через _777"},
+		{"trash_grace_days", &c.TrashGraceDays, "сколько дней копия удалённого сайта лежит в корзине"},
+		{"backup_dir", &c.BackupDir, "резервные копии сайтов (и временные файлы при хранении в S3)"},
+		{"backup_interval", &c.BackupInterval, "часов между резервными копиями каждого сайта; 0 — только autodeploy backup create"},
+		{"backup_keep_daily", &c.BackupKeepDaily, "хранить последнюю копию за каждый из стольких дней"},
+		{"backup_keep_weekly", &c.BackupKeepWeekly, "... за каждую из стольких недель"},
+		{"backup_keep_monthly", &c.BackupKeepMonthly, "... за каждый из стольких месяцев"},
+		{"backup_s3_endpoint", &c.BackupS3Endpoint, "S3-совместимое хранилище для копий, например https://s3.amazonaws.com или http://127.0.0.1:9000 (MinIO); пусто — backup_dir"},
+		{"backup_s3_bucket", &c.BackupS3Bucket, "бакет S3"},
+		{"backup_s3_region", &c.BackupS3Region, "регион S3 (для подписи запросов)"},
+		{"backup_s3_access_key", &c.BackupS3AccessKey, "ключ доступа S3"},
+		{"backup_s3_secret_key", &c.BackupS3SecretKey, "секретный ключ S3 (удобнее задать в AUTODEPLOY_BACKUP_S3_SECRET_KEY)"},
+		{"mysql_socket", &c.MySQLSocket, "unix-сокет MariaDB/MySQL (root входит через unix_socket)"},
+		{"mysql_timeout", &c.MySQLTimeout, "сколько секунд ждать ответа MySQL на один запрос"},
+		{"sites_file", &c.SitesFile, "реестр сайтов: база и пользователь MySQL каждого домена"},
+		{"cloudflare_api_url", &c.CloudflareAPIURL, "адрес API Cloudflare v4 (другой — например, для локальной заглушки)"},
+		{"cloudflare_timeout", &c.CloudflareTimeout, "сколько секунд ждать ответа API Cloudflare"},
+		{"zone_wait_attempts", &c.ZoneWaitAttempts, "сколько раз ждать, пока зона Cloudflare станет active"},
+		{"zone_wait_seconds", &c.ZoneWaitSeconds, "пауза между проверками статуса зоны, сек"},
+		{"text_check_attempts", &c.TextCheckAttempts, "сколько раз искать проверочный текст на сайте"},
+		{"text_check_seconds", &c.TextCheckSeconds, "пауза между проверками текста, сек"},
+		{"hooks_dir", &c.HooksDir, "каталог хуков: <фаза>, <фаза>.d/*, sites/<домен>/..."},
+		{"hook_timeout", &c.HookTimeout, "сколько секунд ждать один хук"},
+		{"webhooks", &c.Webhooks, "адреса вебхуков (url, events, secret); в окружении и флаге — url,url"},
+		{"outbox_dir", &c.OutboxDir, "очередь неотправленных вебхуков"},
+		{"webhook_timeout", &c.WebhookTimeout, "сколько секунд ждать ответа вебхука"},
+		{"webhook_attempts", &c.WebhookAttempts, "сколько раз пытаться доставить событие"},
+		{"metrics_listen", &c.MetricsListen, "адрес HTTP для /metrics (Prometheus); пусто — выключено"},
+		{"cloudflare_settings", &c.CFSettings, "настройки зоны после деплоя; в окружении и флаге — key=value,key=value"},
+	}
+}
+
+// setConfigValue разбирает строку из окружения или флага в значение опции.
+func setConfigValue(ptr interface{}, s string) error {
+	switch p := ptr.(type) {
+	case *string:
+		*p = s
+	case *int:
+		n, err := strconv.Atoi(strings.TrimSpace(s))
+		if err != nil {
+			return fmt.Errorf("%q — не целое число", s)
+		}
+		*p = n
+	case *[]cfSetting:
+		var list []cfSetting
+		for _, kv := range strings.Split(s, ",") {
+			k, v, ok := strings.Cut(strings.TrimSpace(kv), "=")
+			if !ok {
+				return fmt.Errorf("%q — ожидалось key=value", kv)
+			}
+			list = append(list, cfSetting{strings.TrimSpace(k), strings.TrimSpace(v)})
+		}
+		*p = list
+	case *[]webhookEndpoint:
+		var list []webhookEndpoint
+		for _, u := range strings.Split(s, ",") {
+			if u = strings.TrimSpace(u); u != "" {
+				list = append(list, webhookEndpoint{URL: u})
+			}
+		}
+		*p = list
+	}
+	return nil
+}
+
+// loadConfig собирает конфиг из файла, окружения и флагов, проверяет его и
+// выставляет глобальные переменные. Возвращает аргументы после флагов
+// (команду) и предупреждения, которые стоит записать в лог.
+func loadConfig(argv []string) ([]string, []string, error) {
+	c := defaultConfig()
+	fs := flag.NewFlagSet("autodeploy", flag.ContinueOnError)
+	path := fs.String("config", CONFIG_FILE, "файл конфига (переменная AUTODEPLOY_CONFIG)")
+	flagValues := map[string]string{}
+	for _, o := range c.options() {
+		key := o.key
+		fs.Func(strings.ReplaceAll(key, "_", "-"), o.doc, func(s string) error {
+			flagValues[key] = s
+			return nil
+		})
+	}
+	if err := fs.Parse(argv); err != nil {
+		return nil, nil, err
+	}
+	configPath := *path
+	if env := os.Getenv("AUTODEPLOY_CONFIG"); env != "" && configPath == CONFIG_FILE {
+		configPath = env
+	}
+
+	var warnings []string
+	data, err := os.ReadFile(configPath)
+	switch {
+	case err == nil:
+		if err := decodeStrict(data, !strings.HasSuffix(configPath, ".json"), c); err != nil {
+			return nil, nil, fmt.Errorf("%s: %v", configPath, err)
+		}
+	case os.IsNotExist(err) && configPath == CONFIG_FILE:
+		// Без файла работаем на значениях по умолчанию
+	default:
+		return nil, nil, err
+	}
+	for _, o := range c.options() {
+		env := "AUTODEPLOY_" + strings.ToUpper(o.key)
+		if v, ok := os.LookupEnv(env); ok {
+			if err := setConfigValue(o.ptr, v); err != nil {
+				return nil, nil, fmt.Errorf("%s: %v", env, err)
+			}
+		}
+		if v, ok := flagValues[o.key]; ok {
+			if err := setConfigValue(o.ptr, v); err != nil {
+				return nil, nil, fmt.Errorf("-%s: %v", strings.ReplaceAll(o.key, "_", "-"), err)
+			}
+		}
+	}
+	warnings, err = c.validate()
+	if err != nil {
+		return nil, nil, err
+	}
+	c.apply()
+	return fs.Args(), warnings, nil
+}
+
+var cfSettingKeyRe = regexp.MustCompile(`^[a-z0-9_]+$`)
+
+// validate проверяет конфиг. Ошибки собираются все сразу; отсутствующие
+// файлы и каталоги — только предупреждения (их может создать установщик позже).
+func (c *autodeployConfig) validate() ([]string, error) {
+	var problems, warnings []string
+	for _, o := range c.options() {
+		switch p := o.ptr.(type) {
+		case *string:
+			if o.key == "log_format" {
+				if *p != "text" && *p != "json" {
+					problems = append(problems, fmt.Sprintf("%s: %q, ожидалось text или json", o.key, *p))
+				}
+				continue
+			}
+			if o.key == "metrics_listen" {
+				if _, _, err := net.SplitHostPort(*p); *p != "" && err != nil {
+					problems = append(problems, fmt.Sprintf("%s: %q — нужен адрес вида 127.0.0.1:9731", o.key, *p))
+				}
+				continue
+			}
+			if o.key == "backup_s3_endpoint" {
+				if u, err := url.Parse(*p); *p != "" && (err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "") {
+					problems = append(problems, fmt.Sprintf("%s: %q — нужен адрес вида https://s3.example.com", o.key, *p))
+				}
+				continue
+			}
+			if o.key == "cloudflare_api_url" {
+				if u, err := url.Parse(*p); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+					problems = append(problems, fmt.Sprintf("%s: %q — нужен адрес вида https://api.cloudflare.com/client/v4", o.key, *p))
+				}
+				continue
+			}
+			if strings.HasPrefix(o.key, "backup_s3_") {
+				continue
+			}
+			if o.key == "default_php_version" {
+				if !phpVersionRe.MatchString(*p) {
+					problems = append(problems, fmt.Sprintf("%s: %q, ожидалась версия вида 8.2", o.key, *p))
+				}
+				continue
+			}
+			if !filepath.IsAbs(*p) {
+				problems = append(problems, fmt.Sprintf("%s: %q — нужен абсолютный путь", o.key, *p))
+			}
+		case *int:
+			min := 0
+			switch o.key {
+			case "workers", "log_retention_days", "text_check_attempts", "hook_timeout", "webhook_timeout", "webhook_attempts", "log_clean_interval", "trash_grace_days", "cloudflare_timeout", "mysql_timeout":
+				min = 1
+			}
+			if *p < min {
+				problems = append(problems, fmt.Sprintf("%s: %d, минимум %d", o.key, *p, min))
+			}
+		}
+	}
+	if c.BackupS3Endpoint != "" && (c.BackupS3Bucket == "" || c.BackupS3AccessKey == "" || c.BackupS3SecretKey == "") {
+		problems = append(problems, "backup_s3_endpoint задан: нужны и backup_s3_bucket, backup_s3_access_key, backup_s3_secret_key")
+	}
+	if c.Workers > 64 {
+		problems = append(problems, fmt.Sprintf("workers: %d, максимум 64", c.Workers))
+	}
+	for i, st := range c.CFSettings {
+		if !cfSettingKeyRe.MatchString(st.Key) || st.Value == "" {
+			problems = append(problems, fmt.Sprintf("cloudflare_settings[%d]: %q=%q — нужны key и value", i, st.Key, st.Value))
+		}
+	}
+	for i, w := range c.Webhooks {
+		if err := w.validate(); err != nil {
+			problems = append(problems, fmt.Sprintf("webhooks[%d]: %v", i, err))
+		}
+	}
+	for _, path := range []string{c.WatchDir, c.TplNoSSLNoWWW, c.TplNoSSLWWW, c.TplSSLNoWWW, c.TplSSLWWW} {
+		if _, err := os.Stat(path); err != nil {
+			warnings = append(warnings, fmt.Sprintf("%s недоступен: %v", path, err))
+		}
+	}
+	if len(problems) > 0 {
+		return warnings, errors.New(strings.Join(problems, "; "))
+	}
+	return warnings, nil
+}
+
+// apply выставляет глобальные переменные, которыми пользуется остальной код.
+func (c *autodeployConfig) apply() {
+	WATCH_DIR = c.WatchDir
+	NGINX_AVAILABLE = c.NginxAvailable
+	NGINX_ENABLED = c.NginxEnabled
+	TPL_NOSSL_NOWWW = c.TplNoSSLNoWWW
+	TPL_NOSSL_WWW = c.TplNoSSLWWW
+	TPL_SSL_NOWWW = c.TplSSLNoWWW
+	TPL_SSL_WWW = c.TplSSLWWW
+	WP_LOG = c.WPLog
+	LOG_DIR = c.LogDir
+	CLOUDFLARE_TXT = c.CloudflareTxt
+	CLOUDFLARE_API_URL = c.CloudflareAPIURL
+	CLOUDFLARE_TIMEOUT = c.CloudflareTimeout
+	JOURNAL_DIR = c.JournalDir
+	STATUS_DIR = c.StatusDir
+	SNAPSHOT_DIR = c.SnapshotDir
+	DEPLOY_WORKERS = c.Workers
+	DEFAULT_PHP_VERSION = c.DefaultPHPVersion
+	LOG_RETENTION_DAYS = c.LogRetentionDays
+	ZONE_WAIT_ATTEMPTS = c.ZoneWaitAttempts
+	ZONE_WAIT_SECONDS = c.ZoneWaitSeconds
+	TEXT_CHECK_ATTEMPTS = c.TextCheckAttempts
+	TEXT_CHECK_SECONDS = c.TextCheckSeconds
+	HOOKS_DIR = c.HooksDir
+	HOOK_TIMEOUT = c.HookTimeout
+	WEBHOOKS = c.Webhooks
+	OUTBOX_DIR = c.OutboxDir
+	WEBHOOK_TIMEOUT = c.WebhookTimeout
+	WEBHOOK_ATTEMPTS = c.WebhookAttempts
+	METRICS_LISTEN = c.MetricsListen
+	LOG_FORMAT = c.LogFormat
+	LOG_MAX_SIZE_MB = c.LogMaxSizeMB
+	LOG_CLEAN_INTERVAL = c.LogCleanInterval
+	AUDIT_LOG = c.AuditLog
+	TRASH_DIR = c.TrashDir
+	TRASH_GRACE_DAYS = c.TrashGraceDays
+	BACKUP_DIR = c.BackupDir
+	BACKUP_INTERVAL = c.BackupInterval
+	BACKUP_KEEP_DAILY = c.BackupKeepDaily
+	BACKUP_KEEP_WEEKLY = c.BackupKeepWeekly
+	BACKUP_KEEP_MONTHLY = c.BackupKeepMonthly
+	BACKUP_S3_ENDPOINT = c.BackupS3Endpoint
+	BACKUP_S3_BUCKET = c.BackupS3Bucket
+	BACKUP_S3_REGION = c.BackupS3Region
+	BACKUP_S3_ACCESS = c.BackupS3AccessKey
+	BACKUP_S3_SECRET = c.BackupS3SecretKey
+	MYSQL_SOCKET = c.MySQLSocket
+	MYSQL_TIMEOUT = c.MySQLTimeout
+	SITES_FILE = c.SitesFile
+	cfDefaultSettings = c.CFSettings
+	activeConfig = c
+}
+
+// activeConfig — конфиг, с которым запущен процесс (для `autodeploy config`).
+var activeConfig *autodeployConfig
+
+// cmdConfig — `autodeploy config`: печатает действующий конфиг в формате
+// config.yaml с описанием каждого ключа. Вывод можно сохранить как основу
+// для /etc/autodeploy/config.yaml.
+// secretOptions — ключи конфига, которые autodeploy config не печатает целиком:
+// вывод копируют в тикеты и чаты.
+var secretOptions = map[string]bool{"backup_s3_secret_key": true}
+
+// maskSecret оставляет от секрета последние 4 символа, чтобы его можно было
+// узнать; короткий секрет скрывается полностью.
+func maskSecret(s string) string {
+	if s == "" {
+		return ""
+	}
+	if r := []rune(s); len(r) > 8 {
+		return "****" + string(r[len(r)-4:])
+	}
+	return "****"
+}
+
+func cmdConfig(args []string) int {
+	fmt.Println("# autodeploy: действующий конфиг")
+	for _, o := range activeConfig.options() {
+		fmt.Printf("\n# %s\n", o.doc)
+		switch p := o.ptr.(type) {
+		case *string:
+			v := *p
+			if secretOptions[o.key] {
+				v = maskSecret(v)
+			}
+			fmt.Printf("%s: %s\n", o.key, strconv.Quote(v))
+		case *int:
+			fmt.Printf("%s: %d\n", o.key, *p)
+		case *[]cfSetting:
+			fmt.Printf("%s:\n", o.key)
+			for _, st := range *p {
+				fmt.Printf("  - key: %s\n    value: %s\n", strconv.Quote(st.Key), strconv.Quote(st.Value))
+			}
+		case *[]webhookEndpoint:
+			if len(*p) == 0 {
+				fmt.Printf("%s: []\n", o.key)
+				continue
+			}
+			fmt.Printf("%s:\n", o.key)
+			for _, w := range *p {
+				fmt.Printf("  - url: %s\n", strconv.Quote(w.URL))
+				if len(w.Events) > 0 {
+					fmt.Printf("    events: [%s]\n", strings.Join(w.Events, ", "))
+				}
+				if w.Secret != "" {
+					fmt.Printf("    secret: %s\n", strconv.Quote(maskSecret(w.Secret)))
+				}
+			}
+		}
+	}
+	return 0
+}
@@ -0,0 +1,198 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"unicode"
+)
+
+// ------------------------------
+// (28) Проверка имени домена и IDN (punycode)
+// ------------------------------
+
+// normalizeDomain приводит имя домена к виду, в котором оно уходит в nginx,
+// certbot, Cloudflare, SQL и пути: нижний регистр, метки с не-ASCII
+// символами — в punycode (xn--…). Результат проверяется по RFC 1123: метки
+// из [a-z0-9-] длиной 1..63 без дефиса по краям, всего не длиннее 253
+// символов, минимум две метки и не публичный суффикс (см. раздел (27)).
+// Нормализация Unicode (NFC) не выполняется: имя берётся как есть.
+func normalizeDomain(name string) (string, error) {
+	if name == "" {
+		return "", fmt.Errorf("пустое имя домена")
+	}
+	for _, r := range name {
+		if strings.ContainsRune("/\\'\"`", r) || unicode.IsSpace(r) || unicode.IsControl(r) {
+			return "", fmt.Errorf("%q: недопустимый символ %q", name, r)
+		}
+	}
+	// Точки IDNA: идеографическая и полноширинные
+	ascii := strings.NewReplacer("\u3002", ".", "\uff0e", ".", "\uff61", ".").Replace(strings.ToLower(name))
+	ascii = strings.TrimSuffix(ascii, ".")
+	labels := strings.Split(ascii, ".")
+	for i, label := range labels {
+		if label == "" {
+			return "", fmt.Errorf("%q: пустая метка", name)
+		}
+		if !isASCII(label) {
+			enc, err := punycodeEncode(label)
+			if err != nil {
+				return "", fmt.Errorf("%q: метка %q: %v", name, label, err)
+			}
+			labels[i] = "xn--" + enc
+		}
+	}
+	ascii = strings.Join(labels, ".")
+	if len(ascii) > 253 {
+		return "", fmt.Errorf("%q: длиннее 253 символов", name)
+	}
+	if len(labels) < 2 {
+		return "", fmt.Errorf("%q: нужен домен хотя бы второго уровня", name)
+	}
+	for _, label := range labels {
+		if len(label) > 63 {
+			return "", fmt.Errorf("%q: метка %s длиннее 63 символов", name, label)
+		}
+		if label[0] == '-' || label[len(label)-1] == '-' {
+			return "", fmt.Errorf("%q: метка %s начинается или заканчивается дефисом", name, label)
+		}
+		for _, c := range []byte(label) {
+			if !(c >= 'a' && c <= 'z' || c >= '0' && c <= '9' || c == '-') {
+				return "", fmt.Errorf("%q: недопустимый символ %q в метке %s", name, c, label)
+			}
+		}
+	}
+	if strings.Trim(labels[len(labels)-1], "0123456789") == "" {
+		return "", fmt.Errorf("%q: похоже на IP-адрес, а не домен", name)
+	}
+	if registrableDomain(ascii) == "" {
+		return "", fmt.Errorf("%q: публичный суффикс, а не домен", name)
+	}
+	return ascii, nil
+}
+
+func isASCII(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if s[i] >= 0x80 {
+			return false
+		}
+	}
+	return true
+}
+
+// rejectFolder переименовывает папку с некорректным именем домена в
+// <имя>_563, не начиная деплой: журнал, логи и статус по такому имени не
+// заводятся.
+func rejectFolder(folderName, realdom string, err error) {
+	logEvent(logEntry{Level: "ERROR", ErrorCode: ErrDomainName.Code},
+		"Папка %q: %v", folderName, err)
+	newName := realdom + "_" + ErrDomainName.Code
+	if rerr := os.Rename(filepath.Join(WATCH_DIR, folderName), filepath.Join(WATCH_DIR, newName)); rerr != nil {
+		log.Printf("[WARN] Не смогли переименовать %q => %q: %v", folderName, newName, rerr)
+	}
+	metrics.deployment("failed", ErrDomainName.Code)
+}
+
+// domainArg проверяет домен из аргументов командной строки; ошибку печатает в stderr.
+func domainArg(arg string) (string, bool) {
+	domain, err := normalizeDomain(arg)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "[ERROR]", err)
+		return "", false
+	}
+	return domain, true
+}
+
+// Параметры Punycode (RFC 3492, раздел 5).
+const (
+	pcBase        = 36
+	pcTMin        = 1
+	pcTMax        = 26
+	pcSkew        = 38
+	pcDamp        = 700
+	pcInitialBias = 72
+	pcInitialN    = 128
+)
+
+// punycodeEncode кодирует метку домена в Punycode (без префикса xn--).
+func punycodeEncode(label string) (string, error) {
+	runes := []rune(label)
+	if len(runes) > 63 {
+		return "", fmt.Errorf("длиннее 63 символов")
+	}
+	var out strings.Builder
+	for _, r := range runes {
+		if r < 0x80 {
+			out.WriteRune(r)
+		}
+	}
+	b := out.Len()
+	h := b
+	if b > 0 {
+		out.WriteByte('-')
+	}
+	n, delta, bias := pcInitialN, 0, pcInitialBias
+	for h < len(runes) {
+		m := int(unicode.MaxRune) + 1
+		for _, r := range runes {
+			if int(r) >= n && int(r) < m {
+				m = int(r)
+			}
+		}
+		delta += (m - n) * (h + 1)
+		n = m
+		for _, r := range runes {
+			if int(r) < n {
+				delta++
+			}
+			if int(r) != n {
+				continue
+			}
+			q := delta
+			for k := pcBase; ; k += pcBase {
+				t := k - bias
+				if t < pcTMin {
+					t = pcTMin
+				} else if t > pcTMax {
+					t = pcTMax
+				}
+				if q < t {
+					break
+				}
+				out.WriteByte(punycodeDigit(t + (q-t)%(pcBase-t)))
+				q = (q - t) / (pcBase - t)
+			}
+			out.WriteByte(punycodeDigit(q))
+			bias = punycodeAdapt(delta, h+1, h == b)
+			delta = 0
+			h++
+		}
+		delta++
+		n++
+	}
+	return out.String(), nil
+}
+
+func punycodeDigit(d int) byte {
+	if d < 26 {
+		return byte('a' + d)
+	}
+	return byte('0' + d - 26)
+}
+
+func punycodeAdapt(delta, numPoints int, first bool) int {
+	if first {
+		delta /= pcDamp
+	} else {
+		delta /= 2
+	}
+	delta += delta / numPoints
+	k := 0
+	for delta > ((pcBase-pcTMin)*pcTMax)/2 {
+		delta /= pcBase - pcTMin
+		k += pcBase
+	}
+	return k + (pcBase-pcTMin+1)*delta/(delta+pcSkew)
+}
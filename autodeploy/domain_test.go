@@ -0,0 +1,79 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+// ------------------------------
+// Имена доменов и IDN (раздел (28))
+// ------------------------------
+
+// Примеры из RFC 3492, раздел 7.1. Кодер не сохраняет пометки регистра,
+// поэтому в (I) ожидается "d", а не "D".
+func TestPunycodeEncodeRFC3492(t *testing.T) {
+	tests := []struct {
+		name string
+		in   string
+		want string
+	}{
+		{"(A) арабский", "ليهمابتكلموشعربي؟", "egbpdaj6bu4bxfgehfvwxn"},
+		{"(B) китайский упрощённый", "他们为什么不说中文", "ihqwcrb4cv8a8dqg056pqjye"},
+		{"(C) китайский традиционный", "他們爲什麽不說中文", "ihqwctvzc91f659drss3x8bo0yb"},
+		{"(D) чешский", "Pročprostěnemluvíčesky", "Proprostnemluvesky-uyb24dma41a"},
+		{"(E) иврит", "למההםפשוטלאמדבריםעברית", "4dbcagdahymbxekheh6e0a7fei0b"},
+		{"(G) японский", "なぜみんな日本語を話してくれないのか", "n8jok5ay5dzabd5bym9f0cm5685rrjetr6pdxa"},
+		{"(I) русский", "почемужеонинеговорятпорусски", "b1abfaaepdrnnbgefbadotcwatmq2g4l"},
+		{"(J) испанский", "PorquénopuedensimplementehablarenEspañol", "PorqunopuedensimplementehablarenEspaol-fmd56a"},
+		{"(L)", "3年B組金八先生", "3B-ww4c5e180e575a65lsy2b"},
+		{"(P)", "MajiでKoiする5秒前", "MajiKoi5-783gue6qz075azm5e"},
+		{"(R)", "そのスピードで", "d9juau41awczczp"},
+		{"(S) только ASCII", "-> $1.00 <-", "-> $1.00 <--"},
+	}
+	for _, tt := range tests {
+		got, err := punycodeEncode(tt.in)
+		if err != nil || got != tt.want {
+			t.Errorf("%s: punycodeEncode = %q, %v; ожидалось %q", tt.name, got, err, tt.want)
+		}
+	}
+}
+
+func TestNormalizeDomain(t *testing.T) {
+	tests := []struct {
+		in   string
+		want string
+	}{
+		{"Example.COM", "example.com"},
+		{"example.com.", "example.com"},
+		{"bücher.de", "xn--bcher-kva.de"},
+		{"München.DE", "xn--mnchen-3ya.de"},
+		{"пример.рф", "xn--e1afmkfd.xn--p1ai"},
+		{"例え。テスト", "xn--r8jz45g.xn--zckzah"},
+		{"sub.example.co.uk", "sub.example.co.uk"},
+		{"xn--e1afmkfd.xn--p1ai", "xn--e1afmkfd.xn--p1ai"},
+	}
+	for _, tt := range tests {
+		if got, err := normalizeDomain(tt.in); err != nil || got != tt.want {
+			t.Errorf("normalizeDomain(%q) = %q, %v; ожидалось %q", tt.in, got, err, tt.want)
+		}
+	}
+	for _, in := range []string{
+		"",
+		"localhost",
+		"exa mple.com",
+		"example..com",
+		"-example.com",
+		"example-.com",
+		"exa_mple.com",
+		"example.com/x",
+		"1.2.3.4",
+		"co.uk",
+		"рф",
+		strings.Repeat("a", 64) + ".com",
+		strings.Repeat("a.", 127) + "com",
+	} {
+		if got, err := normalizeDomain(in); err == nil {
+			t.Errorf("normalizeDomain(%q) = %q, ожидалась ошибка", in, got)
+		}
+	}
+}
@@ -0,0 +1,113 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+)
+
+// ------------------------------
+// (1) Каталог ошибок деплоя
+// ------------------------------
+
+// deployErrCode — код ошибки деплоя. Code — суффикс, с которым папка сайта
+// переименовывается при ошибке (<домен>_<Code>); он же пишется в лог и в
+// файл статуса. Коды стабильны: их читают люди и внешние скрипты.
+type deployErrCode struct {
+	Code        string `json:"code"`
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	Remedy      string `json:"remedy"`
+}
+
+var (
+	ErrUnknown = &deployErrCode{"500", "unknown",
+		"Неизвестная ошибка",
+		"Смотрите поле output в файле статуса и лог за день (каталог log_dir из конфига)"}
+	ErrCFZoneNotFound = &deployErrCode{"550", "cf_zone_not_found",
+		"Зона домена не найдена ни в одном аккаунте из cloudflare.txt",
+		"Добавьте домен в Cloudflare под одним из аккаунтов cloudflare.txt и повторите деплой"}
+	ErrUnreachable = &deployErrCode{"551", "unreachable",
+		"Сайт не отдал проверочный текст по https://<домен>",
+		"Проверьте, что домен указывает на этот сервер и порты 80/443 открыты"}
+	ErrManifest = &deployErrCode{"552", "manifest_invalid",
+		"Манифест autodeploy.yaml/json отсутствует или некорректен",
+		"Исправьте манифест по тексту ошибки и снова переименуйте папку"}
+	ErrCFZoneInactive = &deployErrCode{"553", "cf_zone_inactive",
+		"Зона в Cloudflare не стала active за время ожидания",
+		"Смените NS у регистратора на NS Cloudflare, дождитесь активации зоны и повторите деплой"}
+	ErrDNSMismatch = &deployErrCode{"554", "dns_mismatch",
+		"DNS-запись домена в Cloudflare отсутствует или указывает не на этот сервер",
+		"Создайте A-запись домена на IP этого сервера и повторите деплой"}
+	ErrStubNginx = &deployErrCode{"555", "stub_nginx_test",
+		"Временный конфиг nginx (затычка) не прошёл nginx -t",
+		"Выполните nginx -t: ошибка может быть и в конфиге другого сайта"}
+	ErrCertbot = &deployErrCode{"556", "certbot",
+		"certbot не смог выпустить сертификат Let's Encrypt",
+		"Проверьте доступность http://<домен>/.well-known/acme-challenge/ и лимиты Let's Encrypt"}
+	ErrWPCLI = &deployErrCode{"557", "wp_cli",
+		"Команда wp-cli завершилась с ошибкой",
+		"Смотрите вывод wp в поле output; проверьте, что wp-cli установлен в /usr/local/bin/wp"}
+	ErrMySQL = &deployErrCode{"558", "mysql",
+		"Ошибка MySQL/MariaDB при создании базы или пользователя",
+		"Проверьте, что MariaDB запущена и root входит без пароля через unix_socket"}
+	ErrTemplate = &deployErrCode{"559", "template",
+		"Финальный шаблон nginx не найден, не отрисован или не прошёл nginx -t",
+		"Проверьте шаблоны в /root/auto_deploy/templates (их создаёт 5.go)"}
+	ErrCFAPI = &deployErrCode{"560", "cf_api",
+		"API Cloudflare недоступно или вернуло ошибку",
+		"Проверьте ключи в cloudflare.txt и доступ сервера к API (cloudflare_api_url); текст ошибки Cloudflare — в поле error"}
+	ErrHook = &deployErrCode{"561", "hook",
+		"pre-хук завершился с ненулевым кодом или по таймауту",
+		"Смотрите вывод хука в поле output, исправьте хук в hooks_dir и выполните autodeploy retry <домен>"}
+	ErrTrash = &deployErrCode{"562", "trash",
+		"Не удалось сохранить копию сайта в корзину, удаление отменено",
+		"Проверьте место на диске и права на trash_dir, работу mysqldump, затем снова переименуйте папку в _777"}
+	ErrDomainName = &deployErrCode{"563", "domain_name",
+		"Имя папки не является корректным именем домена (RFC 1123)",
+		"Переименуйте папку: только буквы, цифры, дефисы и точки (IDN допускаются), затем суффикс статуса"}
+)
+
+// errCatalogue — все коды по порядку (для `autodeploy errors` и поиска по коду).
+var errCatalogue = []*deployErrCode{
+	ErrUnknown, ErrCFZoneNotFound, ErrUnreachable, ErrManifest, ErrCFZoneInactive,
+	ErrDNSMismatch, ErrStubNginx, ErrCertbot, ErrWPCLI, ErrMySQL, ErrTemplate, ErrCFAPI, ErrHook,
+	ErrTrash, ErrDomainName,
+}
+
+// errCodeByCode ищет код в каталоге; незнакомые коды считаются ErrUnknown.
+func errCodeByCode(code string) *deployErrCode {
+	for _, c := range errCatalogue {
+		if c.Code == code {
+			return c
+		}
+	}
+	return ErrUnknown
+}
+
+// deployError — ошибка шага деплоя с кодом из каталога.
+type deployError struct {
+	code *deployErrCode
+	err  error
+}
+
+func (e *deployError) Error() string {
+	return e.err.Error()
+}
+
+func (e *deployError) Unwrap() error {
+	return e.err
+}
+
+// newDeployError создаёт ошибку с кодом code и текстом в стиле fmt.Errorf.
+func newDeployError(code *deployErrCode, format string, args ...interface{}) error {
+	return &deployError{code: code, err: fmt.Errorf(format, args...)}
+}
+
+// errorCodeOf возвращает код ошибки err (ErrUnknown, если кода нет).
+func errorCodeOf(err error) *deployErrCode {
+	var de *deployError
+	if errors.As(err, &de) {
+		return de.code
+	}
+	return ErrUnknown
+}
@@ -0,0 +1,131 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// ------------------------------
+// (19) Хуки деплоя
+// ------------------------------
+
+// Фазы, в которые запускаются хуки. pre-хук с ненулевым кодом прерывает деплой
+// (или удаление) с ошибкой ErrHook; ошибки post-deploy и on-failure только
+// пишутся в лог.
+const (
+	HOOK_PRE_CLOUDFLARE = "pre-cloudflare"
+	HOOK_POST_STUB      = "post-stub"
+	HOOK_PRE_CERTBOT    = "pre-certbot"
+	HOOK_POST_DEPLOY    = "post-deploy"
+	HOOK_PRE_DELETE     = "pre-delete"
+	HOOK_ON_FAILURE     = "on-failure"
+)
+
+// hookPaths возвращает исполняемые хуки фазы в порядке запуска: сначала
+// глобальные (HOOKS_DIR/<фаза>, затем HOOKS_DIR/<фаза>.d/* по алфавиту),
+// потом хуки сайта из HOOKS_DIR/sites/<домен>/ по тем же правилам.
+// Хуки сайта лежат не в его папке: её содержимое загружают по SFTP, а хуки
+// выполняются от root.
+func hookPaths(phase, domain string) []string {
+	var paths []string
+	for _, dir := range []string{HOOKS_DIR, filepath.Join(HOOKS_DIR, "sites", domain)} {
+		if isExecutableFile(filepath.Join(dir, phase)) {
+			paths = append(paths, filepath.Join(dir, phase))
+		}
+		entries, _ := os.ReadDir(filepath.Join(dir, phase+".d"))
+		for _, e := range entries {
+			path := filepath.Join(dir, phase+".d", e.Name())
+			if !strings.HasPrefix(e.Name(), ".") && isExecutableFile(path) {
+				paths = append(paths, path)
+			}
+		}
+	}
+	return paths
+}
+
+func isExecutableFile(path string) bool {
+	fi, err := os.Stat(path)
+	return err == nil && fi.Mode().IsRegular() && fi.Mode()&0111 != 0
+}
+
+// hookSteps — шаг деплоя для хуков фазы (если они есть). Шаг записывается в
+// журнал, поэтому после рестарта хук не запускается повторно.
+func hookSteps(d *deployment, phase string) []deployStep {
+	if len(hookPaths(phase, d.Domain)) == 0 {
+		return nil
+	}
+	name := "hook_" + strings.ReplaceAll(phase, "-", "_")
+	return []deployStep{{name, func(d *deployment) error {
+		out, err := d.runHooks(phase)
+		d.Output = out
+		if err != nil {
+			return newDeployError(ErrHook, "хук %s: %v", phase, err)
+		}
+		return nil
+	}}}
+}
+
+// hookEnv — переменные окружения хука: параметры сайта и пути.
+func (d *deployment) hookEnv(phase string) []string {
+	env := append(os.Environ(),
+		"AUTODEPLOY_PHASE="+phase,
+		"AUTODEPLOY_DOMAIN="+d.Domain,
+		"AUTODEPLOY_SITE_TYPE="+d.SiteType,
+		"AUTODEPLOY_SSL="+d.SSLNeeded,
+		"AUTODEPLOY_WWW="+d.UseWww,
+		"AUTODEPLOY_PHP_VERSION="+d.phpVersion(),
+		"AUTODEPLOY_ALIASES="+strings.Join(d.Aliases, " "),
+		"AUTODEPLOY_REDIRECTS="+strings.Join(d.Redirects, " "),
+		"AUTODEPLOY_FOLDER="+filepath.Join(WATCH_DIR, d.Folder),
+		"AUTODEPLOY_WEBROOT="+d.webroot(),
+		"AUTODEPLOY_NGINX_CONF="+filepath.Join(NGINX_AVAILABLE, d.Domain),
+		"AUTODEPLOY_CERT_DIR="+filepath.Join("/etc/letsencrypt/live", d.Domain),
+		"AUTODEPLOY_JOURNAL="+journalPath(d.Domain),
+		"AUTODEPLOY_STATUS_FILE="+filepath.Join(STATUS_DIR, d.Domain+".json"),
+	)
+	if d.SiteType == "wp" && d.DBName != "" {
+		// До шага wp_database у нового сайта базы ещё нет
+		env = append(env, "AUTODEPLOY_DB_NAME="+d.DBName, "AUTODEPLOY_DB_USER="+d.DBUser)
+	}
+	if d.Status == "failed" {
+		code := errCodeByCode(d.ErrorCode)
+		env = append(env,
+			"AUTODEPLOY_FAILED_STEP="+d.FailedStep,
+			"AUTODEPLOY_ERROR_CODE="+code.Code,
+			"AUTODEPLOY_ERROR_NAME="+code.Name,
+			"AUTODEPLOY_ERROR="+d.Error,
+		)
+	}
+	return env
+}
+
+// runHooks запускает хуки фазы по очереди (каждый не дольше HOOK_TIMEOUT)
+// и останавливается на первом, вернувшем ошибку. Возвращает последние 8 КБ
+// вывода хуков.
+func (d *deployment) runHooks(phase string) (string, error) {
+	tail := &tailBuffer{max: 8192}
+	for _, path := range hookPaths(phase, d.Domain) {
+		d.logf("INFO", "Хук %s для %s: %s", phase, d.Domain, path)
+		fmt.Fprintf(tail, "$ %s\n", path)
+		ctx, cancel := context.WithTimeout(context.Background(), time.Duration(HOOK_TIMEOUT)*time.Second)
+		cmd := exec.CommandContext(ctx, path)
+		cmd.Env = d.hookEnv(phase)
+		cmd.Stdout = io.MultiWriter(os.Stdout, tail)
+		cmd.Stderr = io.MultiWriter(os.Stderr, tail)
+		err := cmd.Run()
+		if ctx.Err() == context.DeadlineExceeded {
+			err = fmt.Errorf("таймаут %d сек", HOOK_TIMEOUT)
+		}
+		cancel()
+		if err != nil {
+			return string(tail.buf), fmt.Errorf("%s: %v", path, err)
+		}
+	}
+	return string(tail.buf), nil
+}
@@ -0,0 +1,352 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// ------------------------------
+// (10) Журнал деплоя (продолжение после падения демона)
+// ------------------------------
+
+// deployment — состояние деплоя одного домена. После каждого шага сохраняется
+// в JOURNAL_DIR/<домен>.json, чтобы после рестарта продолжить с последнего
+// удачного шага, а после ошибки — повторить деплой командой `autodeploy retry`.
+type deployment struct {
+	Domain      string       `json:"domain"`
+	Idx         string       `json:"idx"`
+	Folder      string       `json:"folder"`         // текущее имя папки сайта в WATCH_DIR
+	Status      string       `json:"status"`         // running | failed | done
+	Done        []string     `json:"done"`           // выполненные шаги по порядку
+	Undo        []undoAction `json:"undo,omitempty"` // откат выполненных шагов (см. rollback)
+	Step        string       `json:"step,omitempty"` // текущий (или упавший) шаг
+	FailedStep  string       `json:"failed_step,omitempty"`
+	Error       string       `json:"error,omitempty"`
+	ErrorCode   string       `json:"error_code,omitempty"` // суффикс папки при ошибке
+	Output      string       `json:"output,omitempty"`     // вывод последней команды шага
+	SiteType    string       `json:"site_type"`
+	SSLNeeded   string       `json:"ssl_needed"`
+	UseWww      string       `json:"use_www"`
+	PHPVersion  string       `json:"php_version,omitempty"`
+	Aliases     []string     `json:"aliases,omitempty"`
+	Redirects   []string     `json:"redirects,omitempty"` // алиасы с 301 на основной домен
+	WPTitle     string       `json:"wp_title,omitempty"`
+	WPLocale    string       `json:"wp_locale,omitempty"`
+	DBName      string       `json:"db_name,omitempty"` // база и пользователь MySQL (раздел (30))
+	DBUser      string       `json:"db_user,omitempty"`
+	DBPass      string       `json:"db_pass,omitempty"`
+	AdminPass   string       `json:"admin_pass,omitempty"`
+	CFZoneID    string       `json:"cf_zone_id,omitempty"`
+	CFZoneName  string       `json:"cf_zone_name,omitempty"` // родительская зона для поддомена
+	CFEmail     string       `json:"cf_email,omitempty"`
+	CFAPIKey    string       `json:"cf_api_key,omitempty"`
+	Trigger     string       `json:"trigger,omitempty"`      // что запустило деплой (для аудита)
+	RenamedFrom string       `json:"renamed_from,omitempty"` // старый домен: деплой — переименование (раздел (25))
+	ClonedFrom  string       `json:"cloned_from,omitempty"`  // исходный домен: деплой — клон (раздел (26))
+	Staging     bool         `json:"staging,omitempty"`      // noindex в конфиге nginx
+	BasicAuth   string       `json:"basic_auth,omitempty"`   // строка htpasswd (пользователь:хеш)
+	Started     time.Time    `json:"started"`
+	Updated     time.Time    `json:"updated"`
+	Finished    time.Time    `json:"finished,omitempty"`
+}
+
+// newDeployment создаёт новый деплой домена из папки folderName со статусом idx.
+func newDeployment(domain, idx, folderName string) *deployment {
+	// (I) Определяем тип сайта, необходимость SSL и использование www
+	siteType := "static"
+	sslNeeded := "no"
+	useWww := "no"
+	switch idx {
+	case "0":
+		siteType = "static"
+		sslNeeded = "no"
+		useWww = "no"
+	case "1":
+		siteType = "static"
+		sslNeeded = "no"
+		useWww = "yes"
+	case "2":
+		siteType = "static"
+		sslNeeded = "yes"
+		useWww = "no"
+	case "3":
+		siteType = "static"
+		sslNeeded = "yes"
+		useWww = "yes"
+	case "4":
+		siteType = "wp"
+		sslNeeded = "no"
+		useWww = "no"
+	case "5":
+		siteType = "wp"
+		sslNeeded = "no"
+		useWww = "yes"
+	case "6":
+		siteType = "wp"
+		sslNeeded = "yes"
+		useWww = "no"
+	case "7":
+		siteType = "wp"
+		sslNeeded = "yes"
+		useWww = "yes"
+	}
+	// У поддомена нет своего www: www.shop.example.com никто не набирает,
+	// а сертификат и DNS-запись на него пришлось бы выпускать отдельно
+	if useWww == "yes" && isSubdomain(domain) {
+		log.Printf("[WARN] %s — поддомен, www для него не используется", domain)
+		useWww = "no"
+	}
+	return &deployment{
+		Domain:    domain,
+		Idx:       idx,
+		Folder:    folderName,
+		Status:    "running",
+		SiteType:  siteType,
+		SSLNeeded: sslNeeded,
+		UseWww:    useWww,
+		Started:   time.Now(),
+	}
+}
+
+func journalPath(domain string) string {
+	return filepath.Join(JOURNAL_DIR, domain+".json")
+}
+
+// loadDeployment читает журнал деплоя домена.
+func loadDeployment(domain string) (*deployment, error) {
+	data, err := os.ReadFile(journalPath(domain))
+	if err != nil {
+		return nil, err
+	}
+	d := &deployment{}
+	if err := json.Unmarshal(data, d); err != nil {
+		return nil, fmt.Errorf("повреждён журнал %s: %v", journalPath(domain), err)
+	}
+	return d, nil
+}
+
+// listDeployments читает все журналы из JOURNAL_DIR.
+func listDeployments() []*deployment {
+	entries, _ := os.ReadDir(JOURNAL_DIR)
+	var list []*deployment
+	for _, e := range entries {
+		if e.IsDir() || !strings.HasSuffix(e.Name(), ".json") {
+			continue
+		}
+		d, err := loadDeployment(strings.TrimSuffix(e.Name(), ".json"))
+		if err != nil {
+			log.Printf("[WARN] %v", err)
+			continue
+		}
+		list = append(list, d)
+	}
+	return list
+}
+
+// save атомарно (через временный файл и rename) записывает журнал на диск.
+func (d *deployment) save() {
+	d.Updated = time.Now()
+	data, err := json.MarshalIndent(d, "", "  ")
+	if err != nil {
+		d.logf("ERROR", "Не смогли сериализовать журнал %s: %v", d.Domain, err)
+		return
+	}
+	if err := os.MkdirAll(JOURNAL_DIR, 0700); err != nil {
+		d.logf("ERROR", "Не смогли создать %s: %v", JOURNAL_DIR, err)
+		return
+	}
+	if err := writeFileAtomic(journalPath(d.Domain), data, 0600); err != nil {
+		d.logf("ERROR", "Не смогли записать журнал %s: %v", d.Domain, err)
+	}
+	d.writeStatus()
+}
+
+// writeFileAtomic пишет файл через временный файл, fsync и rename: после
+// падения на диске остаётся либо старая, либо новая версия целиком.
+func writeFileAtomic(path string, data []byte, perm os.FileMode) error {
+	tmp := path + ".tmp"
+	f, err := os.OpenFile(tmp, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, perm)
+	if err != nil {
+		return err
+	}
+	_, err = f.Write(data)
+	if err == nil {
+		err = f.Sync()
+	}
+	f.Close()
+	if err == nil {
+		err = os.Rename(tmp, path)
+	}
+	return err
+}
+
+func (d *deployment) isDone(step string) bool {
+	for _, s := range d.Done {
+		if s == step {
+			return true
+		}
+	}
+	return false
+}
+
+// renameFailed переименовывает папку сайта в <домен>_<suffix> (код ошибки из
+// каталога, см. deployErrCode).
+func (d *deployment) renameFailed(suffix string) {
+	d.ErrorCode = suffix
+	newName := fmt.Sprintf("%s_%s", d.Domain, suffix)
+	d.logf("INFO", "Переименовываем => %s", newName)
+	os.Rename(filepath.Join(WATCH_DIR, d.Folder), filepath.Join(WATCH_DIR, newName))
+	d.Folder = newName
+}
+
+// cf — аккаунт Cloudflare, найденный на шаге cloudflare.
+func (d *deployment) cf() cfAccount {
+	return cfAccount{ZoneID: d.CFZoneID, ZoneName: d.CFZoneName, Email: d.CFEmail, APIKey: d.CFAPIKey}
+}
+
+// ownCFZone — зона Cloudflare принадлежит самому домену. Настройки зоны (ssl,
+// security_level, tls_1_3, …) действуют на все её домены, поэтому у поддомена
+// в родительской зоне (staging.example.com в зоне example.com) они не
+// меняются: иначе деплой копии переключил бы и основной сайт. Пустое имя —
+// журнал, записанный до поиска родительских зон: тогда зона — сам домен.
+func (d *deployment) ownCFZone() bool {
+	return d.CFZoneName == "" || d.CFZoneName == d.Domain
+}
+
+// skipCFZoneSettings пишет в лог, что настройки зоны what пропущены, если
+// зона не своя (см. ownCFZone), и сообщает об этом.
+func (d *deployment) skipCFZoneSettings(what string) bool {
+	if d.ownCFZone() {
+		return false
+	}
+	d.logf("INFO", "Пропускаем %s для %s: зона Cloudflare %s общая с другими сайтами, её настройки не меняем.", what, d.Domain, d.CFZoneName)
+	return true
+}
+
+// phpVersion — версия PHP-FPM сайта.
+func (d *deployment) phpVersion() string {
+	if d.PHPVersion != "" {
+		return d.PHPVersion
+	}
+	return DEFAULT_PHP_VERSION
+}
+
+// webroot — каталог сайта.
+func (d *deployment) webroot() string {
+	return filepath.Join(WATCH_DIR, d.Domain)
+}
+
+// extraNames — все дополнительные домены сайта: алиасы и редиректы.
+func (d *deployment) extraNames() []string {
+	return append(append([]string{}, d.Aliases...), d.Redirects...)
+}
+
+// primaryHost — адрес, на который ведут редиректы: с www или без, как в шаблоне.
+func (d *deployment) primaryHost() string {
+	if d.UseWww == "yes" {
+		return "www." + d.Domain
+	}
+	return d.Domain
+}
+
+// redirectServerBlocks — server-блоки nginx с 301 с алиасов d.Redirects на
+// основной домен. Сертификат общий с основным доменом (certbot выпускает его
+// на все имена сайта).
+func redirectServerBlocks(d *deployment) string {
+	names := strings.Join(d.Redirects, " ")
+	if d.SSLNeeded != "yes" {
+		return fmt.Sprintf(`
+# Алиасы с редиректом на основной домен
+server {
+    listen 80;
+    server_name %s;
+    return 301 http://%s$request_uri;
+}
+`, names, d.primaryHost())
+	}
+	live := filepath.Join("/etc/letsencrypt/live", d.Domain)
+	return fmt.Sprintf(`
+# Алиасы с редиректом на основной домен
+server {
+    listen 80;
+    server_name %[1]s;
+    return 301 https://%[2]s$request_uri;
+}
+
+server {
+    listen 443 ssl;
+    server_name %[1]s;
+
+    ssl_certificate %[3]s/fullchain.pem;
+    ssl_certificate_key %[3]s/privkey.pem;
+
+    return 301 https://%[2]s$request_uri;
+}
+`, names, d.primaryHost(), live)
+}
+
+// ------------------------------
+// (11) Команда `autodeploy retry <домен>`
+// ------------------------------
+
+// cmdRetry повторяет деплой домена. Прерванный деплой (статус running: демон
+// упал посреди шага) продолжается с шага, на котором остановился, как при
+// рестарте демона. Неудавшийся деплой (failed) уже откачен и сервер в
+// исходном состоянии, поэтому retry выполняет все шаги заново.
+func cmdRetry(args []string) int {
+	if len(args) != 1 {
+		fmt.Fprintln(os.Stderr, "Использование: autodeploy retry <домен>")
+		return 2
+	}
+	domain, ok := domainArg(args[0])
+	if !ok {
+		return 2
+	}
+	unlock, err := lockDomain(domain)
+	if err != nil {
+		log.Printf("[ERROR] Не смогли заблокировать домен %s: %v", domain, err)
+		return 1
+	}
+	defer unlock()
+	d, err := loadDeployment(domain)
+	if err != nil {
+		log.Printf("[ERROR] Нет журнала деплоя для %s: %v", domain, err)
+		return 1
+	}
+	if d.Status == "done" {
+		log.Printf("[INFO] Деплой %s уже завершён, повторять нечего.", d.Domain)
+		return 0
+	}
+	d.Trigger = "cli:retry"
+	if d.Status == "running" {
+		log.Printf("[INFO] Продолжаем прерванный деплой %s с шага %s (выполнено: %v)...", d.Domain, d.Step, d.Done)
+	} else {
+		log.Printf("[INFO] Повторяем деплой %s с первого шага (папка %s, прошлая попытка остановилась на шаге %s: %s)...",
+			d.Domain, d.Folder, d.FailedStep, d.Error)
+		if len(d.Undo) > 0 {
+			// Откат прошлой попытки не закончился (демон упал посреди него)
+			d.rollback()
+		}
+	}
+	if !runDeployment(d) {
+		return 1
+	}
+	return 0
+}
+
+// cmdErrors — `autodeploy errors [код]`: печатает каталог кодов ошибок
+// (или один код) с описанием и рекомендацией.
+func cmdErrors(args []string) int {
+	for _, c := range errCatalogue {
+		if len(args) > 0 && args[0] != c.Code && args[0] != c.Name {
+			continue
+		}
+		fmt.Printf("%s  %-18s %s\n     Что делать: %s\n", c.Code, c.Name, c.Description, c.Remedy)
+	}
+	return 0
+}
@@ -0,0 +1,204 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ------------------------------
+// (2) Очистка логов старше LOG_RETENTION_DAYS дней
+// ------------------------------
+func cleanOldLogs() {
+	log.Printf("[INFO] Очистка логов старше %d дней...", LOG_RETENTION_DAYS)
+	filepath.Walk(LOG_DIR, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return nil
+		}
+		if info.IsDir() && path == filepath.Dir(domainLogPath("x")) {
+			// Логи сайтов — их полная история, по сроку не удаляются
+			return filepath.SkipDir
+		}
+		if !info.Mode().IsRegular() {
+			return nil
+		}
+		if time.Since(info.ModTime()).Hours() > float64(24*LOG_RETENTION_DAYS) {
+			os.Remove(path)
+		}
+		return nil
+	})
+}
+
+// ------------------------------
+// (3) Лог: текст или JSON, ротация, лог каждого домена
+// ------------------------------
+
+// logEntry — одна запись лога. Обычные log.Printf("[INFO] ...") попадают сюда
+// только с уровнем и текстом; записи о деплое (d.logf, logEvent) — ещё и с
+// доменом, шагом, кодом ошибки и длительностью.
+type logEntry struct {
+	Time       time.Time `json:"time"`
+	Level      string    `json:"level"`
+	Msg        string    `json:"msg"`
+	Domain     string    `json:"domain,omitempty"`
+	Step       string    `json:"step,omitempty"`
+	ErrorCode  string    `json:"error_code,omitempty"`
+	DurationMs int64     `json:"duration_ms,omitempty"`
+	Output     string    `json:"output,omitempty"` // только в логе домена
+}
+
+// format — строка лога в формате LOG_FORMAT (text — как раньше: время, [LEVEL], текст).
+func (e logEntry) format() []byte {
+	if LOG_FORMAT == "json" {
+		data, _ := json.Marshal(e)
+		return append(data, '\n')
+	}
+	line := e.Time.Format("2006/01/02 15:04:05") + " [" + strings.ToUpper(e.Level) + "] " + e.Msg
+	if e.DurationMs > 0 {
+		line += fmt.Sprintf(" (%s)", time.Duration(e.DurationMs)*time.Millisecond)
+	}
+	if e.Output != "" {
+		line += "\n" + strings.TrimRight(e.Output, "\n")
+	}
+	return []byte(line + "\n")
+}
+
+// logSink — вывод стандартного логгера: stdout (journald) и файл
+// LOG_DIR/ДД.ММ.ГГГГ.log, который сменяется в полночь и при превышении
+// LOG_MAX_SIZE_MB (старый переименовывается в ДД.ММ.ГГГГ.N.log). Записи с
+// доменом дублируются в LOG_DIR/domains/<домен>.log — полную историю сайта;
+// эти файлы ротируются по размеру и не удаляются по сроку хранения.
+type logSink struct {
+	mu   sync.Mutex
+	day  string
+	file *os.File
+	size int64
+}
+
+var logs = &logSink{}
+
+// open открывает файл лога за сегодня (вызывается под mu или до начала работы).
+func (s *logSink) open() error {
+	if err := os.MkdirAll(LOG_DIR, 0755); err != nil {
+		return err
+	}
+	day := time.Now().Format("02.01.2006")
+	path := filepath.Join(LOG_DIR, day+".log")
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return err
+	}
+	fi, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return err
+	}
+	if s.file != nil {
+		s.file.Close()
+	}
+	s.day, s.file, s.size = day, f, fi.Size()
+	LOG_FILE = path
+	return nil
+}
+
+// Write принимает строки стандартного логгера (флаги выключены, время ставим сами).
+func (s *logSink) Write(p []byte) (int, error) {
+	msg := strings.TrimRight(string(p), "\n")
+	level := "info"
+	if strings.HasPrefix(msg, "[") {
+		if end := strings.Index(msg, "] "); end > 0 && end < 8 {
+			level = strings.ToLower(msg[1:end])
+			msg = msg[end+2:]
+		}
+	}
+	s.write(logEntry{Time: time.Now(), Level: level, Msg: msg})
+	return len(p), nil
+}
+
+func (s *logSink) write(e logEntry) {
+	output := e.Output
+	e.Output = ""
+	line := e.format()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	os.Stdout.Write(line)
+	if s.file == nil || s.day != e.Time.Format("02.01.2006") {
+		if err := s.open(); err != nil {
+			fmt.Fprintln(os.Stderr, "[ERROR] Не удалось открыть лог:", err)
+		}
+	} else if LOG_MAX_SIZE_MB > 0 && s.size+int64(len(line)) > int64(LOG_MAX_SIZE_MB)<<20 {
+		s.file.Close()
+		s.file = nil
+		rotateLogFile(LOG_FILE)
+		if err := s.open(); err != nil {
+			fmt.Fprintln(os.Stderr, "[ERROR] Не удалось открыть лог:", err)
+		}
+	}
+	if s.file != nil {
+		n, _ := s.file.Write(line)
+		s.size += int64(n)
+	}
+	if e.Domain != "" {
+		e.Output = output
+		appendDomainLog(e.Domain, e.format())
+	}
+}
+
+// rotateLogFile переименовывает path (x.log) в первый свободный x.N.log.
+func rotateLogFile(path string) {
+	base := strings.TrimSuffix(path, ".log")
+	for n := 1; ; n++ {
+		rotated := fmt.Sprintf("%s.%d.log", base, n)
+		if _, err := os.Stat(rotated); os.IsNotExist(err) {
+			os.Rename(path, rotated)
+			return
+		}
+	}
+}
+
+// domainLogPath — лог одного сайта.
+func domainLogPath(domain string) string {
+	return filepath.Join(LOG_DIR, "domains", domain+".log")
+}
+
+// appendDomainLog дописывает строку в лог домена (вызывается под logs.mu).
+func appendDomainLog(domain string, line []byte) {
+	path := domainLogPath(domain)
+	os.MkdirAll(filepath.Dir(path), 0755)
+	if fi, err := os.Stat(path); err == nil && LOG_MAX_SIZE_MB > 0 && fi.Size()+int64(len(line)) > int64(LOG_MAX_SIZE_MB)<<20 {
+		rotateLogFile(path)
+	}
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return
+	}
+	f.Write(line)
+	f.Close()
+}
+
+// logEvent пишет структурированную запись: в общий лог и в лог домена.
+func logEvent(e logEntry, format string, args ...interface{}) {
+	e.Time = time.Now()
+	e.Level = strings.ToLower(e.Level)
+	e.Msg = fmt.Sprintf(format, args...)
+	logs.write(e)
+}
+
+// logf — запись о деплое d: с доменом и текущим шагом.
+func (d *deployment) logf(level, format string, args ...interface{}) {
+	logEvent(logEntry{Level: level, Domain: d.Domain, Step: d.Step}, format, args...)
+}
+
+// logRetention удаляет старые логи раз в LOG_CLEAN_INTERVAL минут (раньше
+// очистка запускалась на каждое событие inotify).
+func logRetention() {
+	for {
+		cleanOldLogs()
+		time.Sleep(time.Duration(LOG_CLEAN_INTERVAL) * time.Minute)
+	}
+}
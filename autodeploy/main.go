@@ -0,0 +1,436 @@
+// autodeploy — демон, который разворачивает сайты из папок WATCH_DIR (nginx,
+// certbot, Cloudflare, WordPress), и его команды: retry, plan, config, audit,
+// restore, backup, rename, clone, sites. Разделы пронумерованы по файлам:
+//
+//	main.go                   глобальные переменные, main, обработка папки
+//	errors.go      (1)        каталог ошибок деплоя
+//	log.go         (2)–(3)    очистка и запись логов
+//	config.go      (4)        конфиг /etc/autodeploy/config.yaml
+//	watch.go       (5)–(6)    наблюдение за WATCH_DIR, имена папок
+//	workers.go     (7)        воркеры и блокировки
+//	yaml.go        (8)        мини-парсер YAML
+//	manifest.go    (9)        манифест сайта
+//	journal.go     (10)–(11)  журнал деплоя, autodeploy retry
+//	steps.go       (12)–(15)  шаги деплоя
+//	status.go      (16)       файл статуса деплоя
+//	rollback.go    (17)       откат
+//	plan.go        (18)       autodeploy plan
+//	hooks.go       (19)       хуки
+//	webhooks.go    (20)       вебхуки
+//	metrics.go     (21)       метрики Prometheus
+//	audit.go       (22)       журнал аудита
+//	trash.go       (23)       корзина и autodeploy restore
+//	backup.go      (24)       резервные копии
+//	rename.go      (25)       autodeploy rename
+//	clone.go       (26)       autodeploy clone
+//	psl.go         (27)       публичные суффиксы
+//	domain.go      (28)       имена доменов и IDN
+//	mysql.go       (29)       клиент MySQL
+//	sites.go       (30)       реестр сайтов
+//	cloudflare.go  (31)–(35)  Cloudflare
+//
+// Сборка и тесты без go.mod:
+//
+//	cd autodeploy && GO111MODULE=off go build && GO111MODULE=off go test
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"log"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// ------------------------------
+// Глобальные переменные (как в bash)
+// ------------------------------
+const (
+	CONFIG_FILE       = "/etc/autodeploy/config.yaml"
+	STATUS_FILE       = ".autodeploy-status.json"
+	SERVER_IP_COMMAND = `hostname -I | awk '{print $1}'`
+)
+
+// Пути и параметры поведения задаются конфигом (см. раздел (4) и
+// defaultConfig — там же значения по умолчанию).
+var (
+	WATCH_DIR           string
+	NGINX_AVAILABLE     string
+	NGINX_ENABLED       string
+	TPL_NOSSL_NOWWW     string
+	TPL_NOSSL_WWW       string
+	TPL_SSL_NOWWW       string
+	TPL_SSL_WWW         string
+	WP_LOG              string
+	LOG_DIR             string
+	CLOUDFLARE_TXT      string
+	CLOUDFLARE_API_URL  string // адрес API Cloudflare v4 (раздел (35))
+	CLOUDFLARE_TIMEOUT  int    // сколько секунд ждать ответа API Cloudflare
+	JOURNAL_DIR         string
+	STATUS_DIR          string
+	SNAPSHOT_DIR        string
+	DEPLOY_WORKERS      int // сколько доменов деплоится одновременно
+	DEFAULT_PHP_VERSION string
+	LOG_RETENTION_DAYS  int
+	ZONE_WAIT_ATTEMPTS  int // сколько раз ждать, пока зона Cloudflare станет active
+	ZONE_WAIT_SECONDS   int
+	TEXT_CHECK_ATTEMPTS int // сколько раз искать проверочный текст на сайте
+	TEXT_CHECK_SECONDS  int
+	HOOKS_DIR           string
+	HOOK_TIMEOUT        int // сек на один хук
+	WEBHOOKS            []webhookEndpoint
+	OUTBOX_DIR          string
+	WEBHOOK_TIMEOUT     int // сек на одну доставку
+	WEBHOOK_ATTEMPTS    int // после стольких неудач сообщение уходит в OUTBOX_DIR/dead
+	METRICS_LISTEN      string
+	LOG_FORMAT          string // text | json
+	LOG_MAX_SIZE_MB     int    // 0 — без ротации по размеру
+	LOG_CLEAN_INTERVAL  int    // минут между очистками старых логов
+	AUDIT_LOG           string
+	TRASH_DIR           string
+	TRASH_GRACE_DAYS    int // сколько дней хранить копию удалённого сайта
+	BACKUP_DIR          string
+	BACKUP_INTERVAL     int // часов между резервными копиями сайта; 0 — только вручную
+	BACKUP_KEEP_DAILY   int
+	BACKUP_KEEP_WEEKLY  int
+	BACKUP_KEEP_MONTHLY int
+	BACKUP_S3_ENDPOINT  string // пусто — копии хранятся в BACKUP_DIR
+	BACKUP_S3_BUCKET    string
+	BACKUP_S3_REGION    string
+	BACKUP_S3_ACCESS    string
+	BACKUP_S3_SECRET    string
+	MYSQL_SOCKET        string
+	MYSQL_TIMEOUT       int // сек на один запрос к MySQL
+	SITES_FILE          string
+)
+
+// ------------------------------
+// Глобальные (вычислим при старте)
+// ------------------------------
+var (
+	SERVER_IP     string
+	LOG_FILE      string
+	useCloudflare = true // Флаг для использования CloudFlare
+)
+
+// ------------------------------
+// Вспомогательные функции
+// ------------------------------
+
+// runCmd запускает системную команду (name + args) и возвращает ошибку (если есть).
+// stdout/stderr транслируется в общий logger (через io.MultiWriter).
+func runCmd(name string, args ...string) error {
+	cmd := exec.Command(name, args...)
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	return cmd.Run()
+}
+
+// runCmdOutput запускает команду и возвращает её stdout как строку (trimmed).
+// При ошибке возвращает err.
+func runCmdOutput(name string, args ...string) (string, error) {
+	cmd := exec.Command(name, args...)
+	var out bytes.Buffer
+	cmd.Stdout = &out
+	cmd.Stderr = os.Stderr
+	err := cmd.Run()
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(out.String()), nil
+}
+
+// sleepSec — пауза в секундах с логированием
+func sleepSec(sec int) {
+	log.Printf("[INFO] Спим %d сек...", sec)
+	time.Sleep(time.Duration(sec) * time.Second)
+}
+
+// ------------------------------
+// MAIN
+// ------------------------------
+func main() {
+	args, warnings, err := loadConfig(os.Args[1:])
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "[ERROR] Конфиг:", err)
+		os.Exit(2)
+	}
+	ip, err := runCmdOutput("bash", "-c", SERVER_IP_COMMAND)
+	if err == nil {
+		SERVER_IP = ip
+	} else {
+		SERVER_IP = "127.0.0.1"
+	}
+	if err := logs.open(); err != nil {
+		fmt.Println("[ERROR] Не удалось открыть лог в LOG_DIR:", err)
+		os.Exit(1)
+	}
+	// Время и уровень расставляет logs (см. раздел (3))
+	log.SetOutput(logs)
+	log.SetFlags(0)
+	log.SetPrefix("")
+	log.Printf("[INFO] Запуск autodeploy.go; LOG_FILE=%s", LOG_FILE)
+	for _, w := range warnings {
+		log.Println("[WARN] Конфиг:", w)
+	}
+
+	// (A) проверка cloudflare.txt
+	if !checkCloudflareFileSimple() {
+		log.Println("[WARN] Данные CloudFlare не найдены или файл пуст. Продолжаем установку без интеграции CloudFlare.")
+		useCloudflare = false
+	}
+
+	// Команды командной строки (без аргументов — режим демона)
+	if len(args) > 0 {
+		switch args[0] {
+		case "retry":
+			os.Exit(cmdRetry(args[1:]))
+		case "errors":
+			os.Exit(cmdErrors(args[1:]))
+		case "plan":
+			os.Exit(cmdPlan(args[1:]))
+		case "config":
+			os.Exit(cmdConfig(args[1:]))
+		case "audit":
+			os.Exit(cmdAudit(args[1:]))
+		case "restore":
+			os.Exit(cmdRestore(args[1:]))
+		case "backup":
+			os.Exit(cmdBackup(args[1:]))
+		case "rename":
+			os.Exit(cmdRename(args[1:]))
+		case "clone":
+			os.Exit(cmdClone(args[1:]))
+		case "sites":
+			os.Exit(cmdSites(args[1:]))
+		default:
+			fmt.Fprintf(os.Stderr, "Неизвестная команда %q. Доступно: retry <домен>, errors [код], plan <папка>, config, audit verify, restore [домен], backup list|create|restore, rename <старый> <новый>, clone <домен> <копия>, sites\n", args[0])
+			os.Exit(2)
+		}
+	}
+
+	// Пул воркеров деплоя: разные домены обрабатываются параллельно
+	jobs := make(chan func(), 256)
+	for i := 0; i < DEPLOY_WORKERS; i++ {
+		go deployWorker(jobs)
+	}
+
+	go logRetention()
+	go trashRetention()
+	go backupScheduler()
+
+	// Доставка вебхуков из очереди (в том числе оставшихся с прошлого запуска)
+	go webhookSender()
+
+	if METRICS_LISTEN != "" {
+		go serveMetrics(METRICS_LISTEN, func() int { return len(jobs) })
+	}
+
+	// Продолжаем деплои, прерванные предыдущим запуском
+	resumeDeployments(jobs)
+
+	// Следим за WATCH_DIR встроенным inotify-наблюдателем (см. watchDir)
+	events := make(chan fsEvent, 64)
+	go watchDir(WATCH_DIR, events)
+	for ev := range events {
+		if ev.Kind != fsCreate && ev.Kind != fsMovedTo {
+			continue
+		}
+		if !ev.IsDir {
+			continue
+		}
+		name := ev.Name
+		jobs <- func() { handleFolder(name) }
+	}
+}
+
+// ------------------------------
+// Обработка одной папки из WATCH_DIR
+// ------------------------------
+func handleFolder(folderName string) {
+	log.Printf("[INFO] Обнаружена папка: %s", folderName)
+	// (C) Проверка наличия статуса (idx 0..7 или 777)
+	realdom, baseIdx, ok := parseFolderName(folderName)
+	if !ok {
+		log.Printf("[ERROR] Папка %s не соответствует статусам 0..7. Пропускаем.", folderName)
+		return
+	}
+	// Имя папки попадает в SQL, server_name, аргументы certbot и пути —
+	// дальше работаем только с проверенным ASCII-именем
+	domain, err := normalizeDomain(realdom)
+	if err != nil {
+		rejectFolder(folderName, realdom, err)
+		return
+	}
+	if domain != realdom {
+		log.Printf("[INFO] Домен %s => %s (punycode)", realdom, domain)
+	}
+	realdom = domain
+	unlock, err := lockDomain(realdom)
+	if err != nil {
+		log.Printf("[ERROR] Не смогли заблокировать домен %s: %v", realdom, err)
+		return
+	}
+	defer unlock()
+	if _, err := os.Stat(filepath.Join(WATCH_DIR, folderName)); err != nil {
+		log.Printf("[INFO] Папки %s уже нет (обработана ранее?), пропускаем.", folderName)
+		return
+	}
+	trigger := "inotify:" + filepath.Join(WATCH_DIR, folderName)
+	// (B) Удаление сайтов с окончанием _777
+	if baseIdx == "777" {
+		removeSite(realdom, folderName, trigger)
+		return
+	}
+	d := newDeployment(realdom, baseIdx, folderName)
+	d.Trigger = trigger
+	if err := loadManifest(d); err != nil {
+		logEvent(logEntry{Level: "ERROR", Domain: realdom, Step: "manifest", ErrorCode: ErrManifest.Code},
+			"Манифест %s: %v", folderName, err)
+		d.renameFailed(ErrManifest.Code)
+		d.Status = "failed"
+		d.Step = "manifest"
+		d.Error = fmt.Sprintf("манифест: %v", err)
+		d.Finished = time.Now()
+		d.writeStatus()
+		emitEvent(d.event(EV_DEPLOY_FAILED))
+		metrics.deployment("failed", ErrManifest.Code)
+		return
+	}
+	// (D)-(N) Деплой по шагам с журналом (см. deploySteps)
+	runDeployment(d)
+}
+
+// loadManifest читает манифест из папки сайта, проверяет его и применяет к d.
+// Манифест удаляется из папки, чтобы nginx не отдавал его наружу: все его
+// параметры сохраняются в журнале.
+func loadManifest(d *deployment) error {
+	m, path, err := siteManifestOf(d)
+	if err != nil || m == nil {
+		return err
+	}
+	m.apply(d)
+	os.Remove(path)
+	d.logf("INFO", "Применён манифест %s: type=%s, ssl=%s, www=%s, php=%s, aliases=%v, redirects=%v",
+		filepath.Base(path), d.SiteType, d.SSLNeeded, d.UseWww, d.phpVersion(), d.Aliases, d.Redirects)
+	return nil
+}
+
+// siteManifestOf находит и проверяет манифест в папке d. Если манифеста нет
+// (и он не обязателен), возвращает nil, "", nil.
+func siteManifestOf(d *deployment) (*siteManifest, string, error) {
+	m, path, err := readManifest(filepath.Join(WATCH_DIR, d.Folder))
+	if err != nil {
+		return nil, path, err
+	}
+	if m == nil {
+		if d.Idx == "m" {
+			return nil, "", fmt.Errorf("папка с суффиксом _m, но нет ни одного из %v", MANIFEST_NAMES)
+		}
+		return nil, "", nil
+	}
+	if err := m.validate(d.Domain, d.Idx == "m"); err != nil {
+		return nil, path, fmt.Errorf("%s: %v", filepath.Base(path), err)
+	}
+	return m, path, nil
+}
+
+// removeSite удаляет сайт realdom (папка folderName с суффиксом _777).
+// trigger — что вызвало удаление (для журнала аудита).
+func removeSite(realdom, folderName, trigger string) {
+	// Хукам нужны параметры сайта: берём их из журнала деплоя, если он есть
+	d, err := loadDeployment(realdom)
+	if err != nil {
+		d = &deployment{Domain: realdom}
+	}
+	d.Folder = folderName
+	d.Step = "remove"
+	d.Trigger = trigger
+	// База и пользователь MySQL — из реестра сайтов (их получают и хуки)
+	dbName, dbUser, err := siteDatabase(realdom)
+	if err != nil {
+		logEvent(logEntry{Level: "ERROR", Domain: realdom, Step: d.Step, ErrorCode: ErrMySQL.Code},
+			"Реестр сайтов: %v, удаление %s остановлено", err, realdom)
+		failed := realdom + "_" + ErrMySQL.Code
+		os.Rename(filepath.Join(WATCH_DIR, folderName), filepath.Join(WATCH_DIR, failed))
+		return
+	}
+	d.DBName, d.DBUser = dbName, dbUser
+	if out, err := d.runHooks(HOOK_PRE_DELETE); err != nil {
+		logEvent(logEntry{Level: "ERROR", Domain: realdom, Step: d.Step, ErrorCode: ErrHook.Code, Output: out},
+			"Хук %s не дал удалить %s: %v", HOOK_PRE_DELETE, realdom, err)
+		failed := realdom + "_" + ErrHook.Code
+		os.Rename(filepath.Join(WATCH_DIR, folderName), filepath.Join(WATCH_DIR, failed))
+		return
+	}
+	bundle, err := d.trashSite()
+	if err != nil {
+		logEvent(logEntry{Level: "ERROR", Domain: realdom, Step: d.Step, ErrorCode: ErrTrash.Code},
+			"Копия %s не сохранена, сайт не удаляем: %v", realdom, err)
+		failed := realdom + "_" + ErrTrash.Code
+		os.Rename(filepath.Join(WATCH_DIR, folderName), filepath.Join(WATCH_DIR, failed))
+		return
+	}
+	d.logf("INFO", "Копия сайта %s сохранена в %s (хранится %d дн., вернуть: autodeploy restore %s)",
+		realdom, bundle, TRASH_GRACE_DAYS, realdom)
+	d.logf("INFO", "Удаляем сайт %s...", realdom)
+	err = mysqlDropDatabase(dbName)
+	d.audit("db_drop", dbName, "удаление сайта", err)
+	if err == nil {
+		err = mysqlDropUser(dbUser)
+		d.audit("db_user_drop", dbUser+"@localhost", "удаление сайта", err)
+	}
+	if err != nil {
+		// Файлы не трогаем: сайт остаётся целым, копия — в корзине
+		logEvent(logEntry{Level: "ERROR", Domain: realdom, Step: d.Step, ErrorCode: ErrMySQL.Code},
+			"База %s не удалена, удаление сайта остановлено: %v", realdom, err)
+		failed := realdom + "_" + ErrMySQL.Code
+		os.Rename(filepath.Join(WATCH_DIR, folderName), filepath.Join(WATCH_DIR, failed))
+		return
+	}
+	for _, path := range removalPaths(realdom, folderName) {
+		if _, err := os.Lstat(path); err != nil {
+			continue
+		}
+		d.audit(removalAuditAction(path), path, "удаление сайта", os.RemoveAll(path))
+	}
+	if err := forgetSite(realdom); err != nil {
+		d.logf("WARN", "Не смогли убрать %s из реестра сайтов: %v", realdom, err)
+	}
+	reloadNginx()
+	d.logf("INFO", "Сайт %s успешно удалён.", realdom)
+	d.Started, d.Finished = time.Time{}, time.Now()
+	emitEvent(d.event(EV_SITE_REMOVED))
+	metrics.removal()
+}
+
+// removalAuditAction — название удаления path в журнале аудита.
+func removalAuditAction(path string) string {
+	switch {
+	case strings.HasPrefix(path, NGINX_AVAILABLE), strings.HasPrefix(path, NGINX_ENABLED):
+		return "nginx_remove"
+	case strings.HasPrefix(path, "/etc/letsencrypt"):
+		return "cert_delete"
+	case strings.HasPrefix(path, WATCH_DIR):
+		return "files_remove"
+	}
+	return "file_remove"
+}
+
+// removalPaths — файлы и каталоги, которые удаляются вместе с сайтом.
+func removalPaths(realdom, folderName string) []string {
+	return []string{
+		filepath.Join(WATCH_DIR, folderName),
+		filepath.Join(NGINX_ENABLED, realdom),
+		filepath.Join(NGINX_AVAILABLE, realdom),
+		filepath.Join("/etc/letsencrypt/live", realdom),
+		filepath.Join("/etc/letsencrypt/archive", realdom),
+		filepath.Join("/etc/letsencrypt/renewal", realdom+".conf"),
+		htpasswdPath(realdom),
+		journalPath(realdom),
+		filepath.Join(STATUS_DIR, realdom+".json"),
+	}
+}
@@ -0,0 +1,200 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// ------------------------------
+// (9) Манифест сайта autodeploy.yaml / autodeploy.json
+// ------------------------------
+
+// MANIFEST_NAMES — имена файла манифеста в загруженной папке (по приоритету).
+var MANIFEST_NAMES = []string{"autodeploy.yaml", "autodeploy.yml", "autodeploy.json"}
+
+// siteManifest — необязательный манифест сайта. Для папки с суффиксом _m он
+// задаёт деплой целиком, для папки с суффиксом 0..7 — уточняет его
+// (указанные поля важнее суффикса).
+type siteManifest struct {
+	Type      string            `json:"type"` // static | wp
+	SSL       *bool             `json:"ssl"`
+	WWW       *bool             `json:"www"`
+	PHP       string            `json:"php"`       // версия PHP-FPM, например "8.2"
+	Aliases   []string          `json:"aliases"`   // обслуживаются как основной домен
+	Redirects []string          `json:"redirects"` // 301 на основной домен
+	WordPress manifestWordPress `json:"wordpress"`
+}
+
+type manifestWordPress struct {
+	Title  string `json:"title"`
+	Locale string `json:"locale"` // например ru_RU
+}
+
+var (
+	phpVersionRe = regexp.MustCompile(`^[0-9]+\.[0-9]+$`)
+	wpLocaleRe   = regexp.MustCompile(`^[a-z]{2,3}(_[A-Z]{2})?$`)
+)
+
+// readManifest ищет и разбирает манифест в dir. Если манифеста нет,
+// возвращает nil, "", nil.
+func readManifest(dir string) (*siteManifest, string, error) {
+	for _, name := range MANIFEST_NAMES {
+		path := filepath.Join(dir, name)
+		data, err := os.ReadFile(path)
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return nil, path, err
+		}
+		m := &siteManifest{}
+		if err := decodeStrict(data, !strings.HasSuffix(name, ".json"), m); err != nil {
+			return nil, path, fmt.Errorf("%s: %v", name, err)
+		}
+		return m, path, nil
+	}
+	return nil, "", nil
+}
+
+// validate проверяет значения манифеста по схеме. requireType — для папок с
+// суффиксом _m, где тип сайта больше неоткуда взять.
+func (m *siteManifest) validate(domain string, requireType bool) error {
+	switch m.Type {
+	case "static", "wp":
+	case "":
+		if requireType {
+			return fmt.Errorf("type: обязательное поле (static или wp)")
+		}
+	default:
+		return fmt.Errorf("type: %q, ожидалось static или wp", m.Type)
+	}
+	if m.PHP != "" {
+		if !phpVersionRe.MatchString(m.PHP) {
+			return fmt.Errorf("php: %q, ожидалась версия вида 8.2", m.PHP)
+		}
+		if _, err := os.Stat(phpSocket(m.PHP)); err != nil {
+			return fmt.Errorf("php: PHP-FPM %s не установлен (нет %s)", m.PHP, phpSocket(m.PHP))
+		}
+	}
+	seen := map[string]bool{domain: true, "www." + domain: true}
+	for _, list := range []struct {
+		key   string
+		names []string
+	}{{"aliases", m.Aliases}, {"redirects", m.Redirects}} {
+		for i, a := range list.names {
+			a, err := normalizeDomain(strings.TrimSpace(a))
+			if err != nil {
+				return fmt.Errorf("%s[%d]: %v", list.key, i, err)
+			}
+			if seen[a] {
+				return fmt.Errorf("%s[%d]: %s повторяется", list.key, i, a)
+			}
+			seen[a] = true
+			list.names[i] = a
+		}
+	}
+	if m.Type == "static" && (m.WordPress.Title != "" || m.WordPress.Locale != "") {
+		return fmt.Errorf("wordpress: задано для статического сайта")
+	}
+	if len(m.WordPress.Title) > 200 {
+		return fmt.Errorf("wordpress.title: длиннее 200 символов")
+	}
+	if m.WordPress.Locale != "" && !wpLocaleRe.MatchString(m.WordPress.Locale) {
+		return fmt.Errorf("wordpress.locale: %q, ожидалось вида ru_RU", m.WordPress.Locale)
+	}
+	return nil
+}
+
+// apply переносит параметры манифеста в деплой и пересчитывает d.Idx —
+// эквивалентный статус 0..7, по которому считаются коды ошибок.
+func (m *siteManifest) apply(d *deployment) {
+	if m.Type != "" {
+		d.SiteType = m.Type
+	}
+	if m.SSL != nil {
+		d.SSLNeeded = yesNo(*m.SSL)
+	}
+	if m.WWW != nil {
+		d.UseWww = yesNo(*m.WWW)
+	}
+	if d.UseWww == "yes" && isSubdomain(d.Domain) {
+		log.Printf("[WARN] %s — поддомен, www для него не используется", d.Domain)
+		d.UseWww = "no"
+	}
+	d.PHPVersion = m.PHP
+	d.Aliases = m.Aliases
+	d.Redirects = m.Redirects
+	d.WPTitle = m.WordPress.Title
+	d.WPLocale = m.WordPress.Locale
+	n := 0
+	if d.SiteType == "wp" {
+		n += 4
+	}
+	if d.SSLNeeded == "yes" {
+		n += 2
+	}
+	if d.UseWww == "yes" {
+		n++
+	}
+	d.Idx = strconv.Itoa(n)
+}
+
+func yesNo(b bool) string {
+	if b {
+		return "yes"
+	}
+	return "no"
+}
+
+// phpSocket — сокет PHP-FPM нужной версии.
+func phpSocket(version string) string {
+	return fmt.Sprintf("/run/php/php%s-fpm.sock", version)
+}
+
+// serverNameRe находит директивы server_name в шаблоне.
+var serverNameRe = regexp.MustCompile(`(server_name[^;]*);`)
+
+// renderTemplate подставляет параметры сайта в шаблон nginx: имя домена,
+// версию PHP и дополнительные домены (дописываются в каждый server_name).
+// Для алиасов с редиректом в конец добавляются отдельные server-блоки.
+func renderTemplate(d *deployment, tplPath string) (string, error) {
+	data, err := os.ReadFile(tplPath)
+	if err != nil {
+		return "", err
+	}
+	text := string(data)
+	if isSubdomain(d.Domain) {
+		// Шаблоны 5.go отвечают на www.<домен> — поддомену это имя не нужно
+		text = strings.ReplaceAll(text, " www.{{ domain_name }}", "")
+	}
+	text = strings.ReplaceAll(text, "{{ domain_name }}", d.Domain)
+	if strings.Contains(text, "{{ php_version }}") {
+		text = strings.ReplaceAll(text, "{{ php_version }}", d.phpVersion())
+	} else {
+		// Шаблоны, созданные старым 5.go, содержат сокет php8.2 как есть
+		text = strings.ReplaceAll(text, phpSocket("8.2"), phpSocket(d.phpVersion()))
+	}
+	if len(d.Aliases) > 0 {
+		text = serverNameRe.ReplaceAllString(text, "${1} "+strings.Join(d.Aliases, " ")+";")
+	}
+	// Копия для тестов (см. `autodeploy clone`) не должна попасть в поиск
+	extra := ""
+	if d.Staging {
+		extra += "\n    add_header X-Robots-Tag \"noindex, nofollow\" always;"
+	}
+	if d.BasicAuth != "" {
+		extra += fmt.Sprintf("\n    auth_basic \"Staging\";\n    auth_basic_user_file %s;", htpasswdPath(d.Domain))
+	}
+	if extra != "" {
+		text = serverNameRe.ReplaceAllString(text, "${1};"+extra)
+	}
+	if len(d.Redirects) > 0 {
+		text += redirectServerBlocks(d)
+	}
+	return text, nil
+}
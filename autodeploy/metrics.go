@@ -0,0 +1,209 @@
+package main
+
+import (
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ------------------------------
+// (21) Метрики Prometheus (/metrics)
+// ------------------------------
+
+// stepBuckets — границы гистограммы длительности шагов, сек. Шаги бывают от
+// долей секунды (rename) до нескольких минут (certbot, ожидание зоны).
+var stepBuckets = []float64{0.1, 0.5, 1, 5, 10, 30, 60, 120, 300, 600}
+
+// metricsRegistry — счётчики демона. Живут в памяти процесса и обнуляются
+// при рестарте (Prometheus это учитывает). Деплои из команд (retry) идут в
+// отдельном процессе и сюда не попадают.
+type metricsRegistry struct {
+	mu          sync.Mutex
+	deployments map[[2]string]float64 // {outcome, code}
+	removals    float64
+	stepCount   map[string]uint64
+	stepSum     map[string]float64
+	stepHist    map[string][]uint64 // по stepBuckets, не накопительно
+	cfRequests  map[string]float64
+	cfFailures  map[string]float64
+}
+
+var metrics = &metricsRegistry{
+	deployments: map[[2]string]float64{},
+	stepCount:   map[string]uint64{},
+	stepSum:     map[string]float64{},
+	stepHist:    map[string][]uint64{},
+	cfRequests:  map[string]float64{},
+	cfFailures:  map[string]float64{},
+}
+
+func (m *metricsRegistry) deployment(outcome, code string) {
+	m.mu.Lock()
+	m.deployments[[2]string{outcome, code}]++
+	m.mu.Unlock()
+}
+
+func (m *metricsRegistry) removal() {
+	m.mu.Lock()
+	m.removals++
+	m.mu.Unlock()
+}
+
+func (m *metricsRegistry) observeStep(step string, d time.Duration) {
+	sec := d.Seconds()
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.stepHist[step] == nil {
+		m.stepHist[step] = make([]uint64, len(stepBuckets))
+	}
+	for i, b := range stepBuckets {
+		if sec <= b {
+			m.stepHist[step][i]++
+			break
+		}
+	}
+	m.stepCount[step]++
+	m.stepSum[step] += sec
+}
+
+// cfCall учитывает запрос к API Cloudflare; endpoint — zones, dns_records или settings.
+func (m *metricsRegistry) cfCall(endpoint string, ok bool) {
+	m.mu.Lock()
+	m.cfRequests[endpoint]++
+	if !ok {
+		m.cfFailures[endpoint]++
+	}
+	m.mu.Unlock()
+}
+
+// serveMetrics отдаёт метрики по HTTP. queueLen — длина очереди деплоя.
+func serveMetrics(addr string, queueLen func() int) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/metrics", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		metrics.write(w, queueLen())
+	})
+	log.Printf("[INFO] Метрики Prometheus: http://%s/metrics", addr)
+	if err := http.ListenAndServe(addr, mux); err != nil {
+		log.Printf("[ERROR] Сервер метрик %s остановлен: %v", addr, err)
+	}
+}
+
+var promLabelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+func promLabel(v string) string {
+	return `"` + promLabelEscaper.Replace(v) + `"`
+}
+
+func promHeader(w io.Writer, name, kind, help string) {
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n", name, help, name, kind)
+}
+
+func sortedKeys(m map[string]float64) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// write печатает метрики в текстовом формате Prometheus.
+func (m *metricsRegistry) write(w io.Writer, queueLen int) {
+	m.mu.Lock()
+	promHeader(w, "autodeploy_deployments_total", "counter", "Завершённые деплои по исходу и коду ошибки.")
+	var deployKeys [][2]string
+	for k := range m.deployments {
+		deployKeys = append(deployKeys, k)
+	}
+	sort.Slice(deployKeys, func(i, j int) bool {
+		return deployKeys[i][0]+deployKeys[i][1] < deployKeys[j][0]+deployKeys[j][1]
+	})
+	for _, k := range deployKeys {
+		fmt.Fprintf(w, "autodeploy_deployments_total{outcome=%s,code=%s} %g\n", promLabel(k[0]), promLabel(k[1]), m.deployments[k])
+	}
+	promHeader(w, "autodeploy_removals_total", "counter", "Сайты, удалённые через суффикс _777.")
+	fmt.Fprintf(w, "autodeploy_removals_total %g\n", m.removals)
+
+	promHeader(w, "autodeploy_step_duration_seconds", "histogram", "Длительность шагов деплоя.")
+	for _, step := range sortedKeys(m.stepSum) {
+		var cum uint64
+		for i, b := range stepBuckets {
+			cum += m.stepHist[step][i]
+			fmt.Fprintf(w, "autodeploy_step_duration_seconds_bucket{step=%s,le=\"%g\"} %d\n", promLabel(step), b, cum)
+		}
+		fmt.Fprintf(w, "autodeploy_step_duration_seconds_bucket{step=%s,le=\"+Inf\"} %d\n", promLabel(step), m.stepCount[step])
+		fmt.Fprintf(w, "autodeploy_step_duration_seconds_sum{step=%s} %g\n", promLabel(step), m.stepSum[step])
+		fmt.Fprintf(w, "autodeploy_step_duration_seconds_count{step=%s} %d\n", promLabel(step), m.stepCount[step])
+	}
+
+	promHeader(w, "autodeploy_cloudflare_requests_total", "counter", "Запросы к API Cloudflare.")
+	for _, ep := range sortedKeys(m.cfRequests) {
+		fmt.Fprintf(w, "autodeploy_cloudflare_requests_total{endpoint=%s} %g\n", promLabel(ep), m.cfRequests[ep])
+	}
+	promHeader(w, "autodeploy_cloudflare_failures_total", "counter", "Неудачные запросы к API Cloudflare.")
+	for _, ep := range sortedKeys(m.cfRequests) {
+		fmt.Fprintf(w, "autodeploy_cloudflare_failures_total{endpoint=%s} %g\n", promLabel(ep), m.cfFailures[ep])
+	}
+	m.mu.Unlock()
+
+	promHeader(w, "autodeploy_queue_length", "gauge", "Папки, ждущие свободного воркера.")
+	fmt.Fprintf(w, "autodeploy_queue_length %d\n", queueLen)
+
+	// Сайты и сертификаты считаем при каждом запросе: это состояние диска
+	sites := map[string]float64{"static": 0, "wp": 0}
+	running := 0
+	for _, d := range listDeployments() {
+		switch d.Status {
+		case "done":
+			sites[d.SiteType]++
+		case "running":
+			running++
+		}
+	}
+	promHeader(w, "autodeploy_sites", "gauge", "Развёрнутые сайты по типу.")
+	for _, t := range sortedKeys(sites) {
+		fmt.Fprintf(w, "autodeploy_sites{type=%s} %g\n", promLabel(t), sites[t])
+	}
+	promHeader(w, "autodeploy_deployments_running", "gauge", "Деплои в процессе (по журналам).")
+	fmt.Fprintf(w, "autodeploy_deployments_running %d\n", running)
+
+	promHeader(w, "autodeploy_certificate_expiry_timestamp_seconds", "gauge", "Окончание сертификата Let's Encrypt (unix time).")
+	entries, _ := os.ReadDir("/etc/letsencrypt/live")
+	for _, e := range entries {
+		if !e.IsDir() {
+			continue
+		}
+		notAfter, err := certNotAfter(filepath.Join("/etc/letsencrypt/live", e.Name(), "cert.pem"))
+		if err != nil {
+			continue
+		}
+		fmt.Fprintf(w, "autodeploy_certificate_expiry_timestamp_seconds{domain=%s} %d\n", promLabel(e.Name()), notAfter.Unix())
+	}
+}
+
+// certNotAfter читает срок действия первого сертификата в PEM-файле.
+func certNotAfter(path string) (time.Time, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return time.Time{}, err
+	}
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return time.Time{}, fmt.Errorf("%s: нет PEM-блока", path)
+	}
+	cert, err := x509.ParseCertificate(block.Bytes)
+	if err != nil {
+		return time.Time{}, err
+	}
+	return cert.NotAfter, nil
+}
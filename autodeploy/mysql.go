@@ -0,0 +1,690 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ------------------------------
+// (29) Клиент MySQL/MariaDB (database/sql поверх unix-сокета)
+// ------------------------------
+
+// Драйвер database/sql на стандартной библиотеке: ровно то, что нужно
+// autodeploy — вход root через unix_socket (без пароля), текстовые запросы и
+// чтение результатов. Параметры «?» подставляются на стороне клиента с
+// экранированием (как interpolateParams у go-sql-driver/mysql): CREATE USER,
+// GRANT и RENAME USER сервер подготовить не может. Имена баз и таблиц
+// параметрами не передаются — их экранирует mysqlIdent.
+//
+// Дампы (mysqldump и загрузка .sql) по-прежнему идут через mysqldump и mysql:
+// в них DELIMITER и другие команды клиента, а не только SQL.
+
+func init() {
+	sql.Register("autodeploy-mysql", mysqlDriver{})
+}
+
+var (
+	mysqlOnce sync.Once
+	mysqlPool *sql.DB
+)
+
+// mysqlDB — общий пул соединений с MYSQL_SOCKET от имени root.
+func mysqlDB() *sql.DB {
+	mysqlOnce.Do(func() {
+		mysqlPool, _ = sql.Open("autodeploy-mysql", MYSQL_SOCKET) // Open только запоминает адрес
+		mysqlPool.SetMaxOpenConns(DEPLOY_WORKERS)
+		mysqlPool.SetConnMaxIdleTime(time.Minute)
+	})
+	return mysqlPool
+}
+
+// mysqlContext — срок на один запрос: зависший сервер не держит воркер
+// дольше MYSQL_TIMEOUT.
+func mysqlContext() (context.Context, context.CancelFunc) {
+	return context.WithTimeout(context.Background(), time.Duration(MYSQL_TIMEOUT)*time.Second)
+}
+
+// mysqlIdent экранирует имя базы или таблицы: `имя` с удвоением `.
+func mysqlIdent(name string) string {
+	return "`" + strings.ReplaceAll(name, "`", "``") + "`"
+}
+
+// mysqlExists выполняет SELECT и сообщает, вернул ли он хоть одну строку.
+func mysqlExists(query string, args ...interface{}) (bool, error) {
+	ctx, cancel := mysqlContext()
+	defer cancel()
+	rows, err := mysqlDB().QueryContext(ctx, query, args...)
+	if err != nil {
+		return false, err
+	}
+	defer rows.Close()
+	found := rows.Next()
+	return found, rows.Err()
+}
+
+// mysqlDatabaseExists — есть ли база name.
+func mysqlDatabaseExists(name string) (bool, error) {
+	return mysqlExists("SELECT 1 FROM information_schema.SCHEMATA WHERE SCHEMA_NAME = ?", name)
+}
+
+// mysqlUserExists — есть ли пользователь 'name'@'localhost'.
+func mysqlUserExists(name string) (bool, error) {
+	return mysqlExists("SELECT 1 FROM mysql.user WHERE User = ? AND Host = 'localhost'", name)
+}
+
+// mysqlExec выполняет запросы по очереди и останавливается на первой ошибке.
+func mysqlExec(queries ...string) error {
+	for _, q := range queries {
+		if err := mysqlRun(q); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// mysqlRun выполняет один запрос с параметрами «?».
+func mysqlRun(query string, args ...interface{}) error {
+	ctx, cancel := mysqlContext()
+	defer cancel()
+	_, err := mysqlDB().ExecContext(ctx, query, args...)
+	return err
+}
+
+// mysqlCreateSite создаёт базу db и пользователя 'user'@'localhost' с паролем
+// pass и правами на неё. Повторный вызов безопасен: пароль заменяется.
+func mysqlCreateSite(db, user, pass string) error {
+	steps := []struct {
+		query string
+		args  []interface{}
+	}{
+		{"CREATE DATABASE IF NOT EXISTS " + mysqlIdent(db), nil},
+		{"CREATE USER IF NOT EXISTS ?@'localhost' IDENTIFIED BY ?", []interface{}{user, pass}},
+		{"ALTER USER ?@'localhost' IDENTIFIED BY ?", []interface{}{user, pass}},
+		{"GRANT ALL ON " + mysqlIdent(db) + ".* TO ?@'localhost'", []interface{}{user}},
+		{"FLUSH PRIVILEGES", nil},
+	}
+	for _, st := range steps {
+		if err := mysqlRun(st.query, st.args...); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// mysqlDropDatabase удаляет базу name, если она есть.
+func mysqlDropDatabase(name string) error {
+	return mysqlRun("DROP DATABASE IF EXISTS " + mysqlIdent(name))
+}
+
+// mysqlDropUser удаляет пользователя 'name'@'localhost', если он есть.
+func mysqlDropUser(name string) error {
+	return mysqlRun("DROP USER IF EXISTS ?@'localhost'", name)
+}
+
+// mysqlColumn выполняет SELECT и возвращает первый столбец всех строк.
+func mysqlColumn(query string, args ...interface{}) ([]string, error) {
+	ctx, cancel := mysqlContext()
+	defer cancel()
+	rows, err := mysqlDB().QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var out []string
+	for rows.Next() {
+		var v string
+		if err := rows.Scan(&v); err != nil {
+			return nil, err
+		}
+		out = append(out, v)
+	}
+	return out, rows.Err()
+}
+
+// mysqlError — ошибка, которую вернул сервер (ERR-пакет).
+type mysqlError struct {
+	Code  uint16
+	State string
+	Msg   string
+}
+
+func (e *mysqlError) Error() string {
+	return fmt.Sprintf("MySQL %d (%s): %s", e.Code, e.State, e.Msg)
+}
+
+var errMySQLPacket = errors.New("MySQL: неожиданный ответ сервера")
+
+// Флаги возможностей клиента и статуса сервера (протокол MySQL 4.1+).
+const (
+	mysqlClientLongPassword  = 0x1
+	mysqlClientLongFlag      = 0x4
+	mysqlClientProtocol41    = 0x200
+	mysqlClientTransactions  = 0x2000
+	mysqlClientSecureConn    = 0x8000
+	mysqlClientPluginAuth    = 0x80000
+	mysqlStatusNoBackslashes = 0x200
+	mysqlMaxPacket           = 0xffffff
+)
+
+type mysqlDriver struct{}
+
+// Open подключается к unix-сокету dsn и входит как root.
+func (mysqlDriver) Open(dsn string) (driver.Conn, error) {
+	nc, err := net.DialTimeout("unix", dsn, 10*time.Second)
+	if err != nil {
+		return nil, err
+	}
+	c := &mysqlConn{nc: nc}
+	nc.SetDeadline(time.Now().Add(30 * time.Second))
+	if err := c.handshake("root"); err != nil {
+		nc.Close()
+		return nil, fmt.Errorf("вход в MySQL: %w", err)
+	}
+	nc.SetDeadline(time.Time{})
+	return c, nil
+}
+
+type mysqlConn struct {
+	nc          net.Conn
+	seq         byte
+	noBackslash bool // сервер в режиме NO_BACKSLASH_ESCAPES: \ в строках — обычный символ
+	broken      bool
+}
+
+func (c *mysqlConn) readPacket() ([]byte, error) {
+	var payload []byte
+	for {
+		var hdr [4]byte
+		if _, err := io.ReadFull(c.nc, hdr[:]); err != nil {
+			c.broken = true
+			return nil, err
+		}
+		n := int(hdr[0]) | int(hdr[1])<<8 | int(hdr[2])<<16
+		c.seq = hdr[3] + 1
+		buf := make([]byte, n)
+		if _, err := io.ReadFull(c.nc, buf); err != nil {
+			c.broken = true
+			return nil, err
+		}
+		payload = append(payload, buf...)
+		if n < mysqlMaxPacket {
+			if len(payload) == 0 {
+				c.broken = true
+				return nil, errMySQLPacket
+			}
+			return payload, nil
+		}
+	}
+}
+
+func (c *mysqlConn) writePacket(data []byte) error {
+	for {
+		n := len(data)
+		if n > mysqlMaxPacket {
+			n = mysqlMaxPacket
+		}
+		pkt := append([]byte{byte(n), byte(n >> 8), byte(n >> 16), c.seq}, data[:n]...)
+		c.seq++
+		if _, err := c.nc.Write(pkt); err != nil {
+			c.broken = true
+			return err
+		}
+		data = data[n:]
+		if n < mysqlMaxPacket {
+			return nil
+		}
+	}
+}
+
+func parseMySQLError(pkt []byte) error {
+	if len(pkt) < 3 {
+		return errMySQLPacket
+	}
+	e := &mysqlError{Code: binary.LittleEndian.Uint16(pkt[1:3])}
+	msg := pkt[3:]
+	if len(msg) >= 6 && msg[0] == '#' {
+		e.State, msg = string(msg[1:6]), msg[6:]
+	}
+	e.Msg = string(msg)
+	return e
+}
+
+// handshake — вход без пароля: root на сервере аутентифицируется плагином
+// unix_socket (auth_socket) по владельцу процесса, ответ клиента не нужен.
+// Пароли клиент не поддерживает: если сервер требует пароль, возвращается
+// ошибка mysqlPasswordError, а не просто «Access denied».
+func (c *mysqlConn) handshake(user string) error {
+	pkt, err := c.readPacket()
+	if err != nil {
+		return err
+	}
+	if pkt[0] == 0xff {
+		return parseMySQLError(pkt)
+	}
+	if pkt[0] != 10 {
+		return fmt.Errorf("неизвестная версия протокола MySQL %d", pkt[0])
+	}
+	// версия сервера\0, id соединения (4), соль (8), 0, возможности (2),
+	// кодировка (1), статус (2), возможности (2), …
+	rest := pkt[1:]
+	i := bytes.IndexByte(rest, 0)
+	if i < 0 || len(rest) < i+1+4+8+1+2 {
+		return errMySQLPacket
+	}
+	rest = rest[i+1+4+8+1:]
+	caps := uint32(binary.LittleEndian.Uint16(rest))
+	if len(rest) >= 7 {
+		c.noBackslash = binary.LittleEndian.Uint16(rest[3:])&mysqlStatusNoBackslashes != 0
+		caps |= uint32(binary.LittleEndian.Uint16(rest[5:])) << 16
+	}
+	if caps&mysqlClientProtocol41 == 0 {
+		return fmt.Errorf("сервер MySQL не поддерживает протокол 4.1")
+	}
+	flags := uint32(mysqlClientLongPassword|mysqlClientLongFlag|mysqlClientProtocol41|
+		mysqlClientTransactions|mysqlClientSecureConn|mysqlClientPluginAuth) & caps
+	resp := binary.LittleEndian.AppendUint32(nil, flags)
+	resp = binary.LittleEndian.AppendUint32(resp, mysqlMaxPacket)
+	resp = append(resp, 45) // utf8mb4_general_ci
+	resp = append(resp, make([]byte, 23)...)
+	resp = append(append(resp, user...), 0)
+	resp = append(resp, 0) // пустой ответ аутентификации
+	if flags&mysqlClientPluginAuth != 0 {
+		resp = append(append(resp, "mysql_native_password"...), 0)
+	}
+	if err := c.writePacket(resp); err != nil {
+		return err
+	}
+	plugin := "mysql_native_password"
+	for {
+		pkt, err := c.readPacket()
+		if err != nil {
+			return err
+		}
+		switch pkt[0] {
+		case 0x00:
+			return nil
+		case 0xff:
+			err := parseMySQLError(pkt)
+			var me *mysqlError
+			if errors.As(err, &me) && (me.Code == 1045 || me.Code == 1698) {
+				// Access denied: пустой ответ не подошёл плагину plugin
+				return mysqlPasswordError(user, plugin, err)
+			}
+			return err
+		case 0xfe:
+			// AuthSwitchRequest: имя плагина\0, соль. Пароля нет, ответ любого
+			// плагина пустой; если плагину нужен пароль, сервер ответит ERR
+			if i := bytes.IndexByte(pkt[1:], 0); i >= 0 {
+				plugin = string(pkt[1 : 1+i])
+			}
+			if err := c.writePacket(nil); err != nil {
+				return err
+			}
+		case 0x01:
+			// AuthMoreData (caching_sha2_password): 3 — вход по кешу, ждём OK;
+			// 4 — серверу нужен полный пароль
+			if len(pkt) > 1 && pkt[1] == 4 {
+				return mysqlPasswordError(user, "caching_sha2_password", nil)
+			}
+		default:
+			return errMySQLPacket
+		}
+	}
+}
+
+// mysqlPasswordError — сервер требует пароль для user, а клиент входит только
+// без пароля (root по unix_socket). cause — ERR-пакет сервера, если он был.
+func mysqlPasswordError(user, plugin string, cause error) error {
+	msg := fmt.Sprintf("MySQL требует пароль для %s@localhost (плагин %s), а вход возможен только без пароля "+
+		"по unix_socket: запустите от root и проверьте, что у %s@localhost плагин unix_socket", user, plugin, user)
+	if cause != nil {
+		return fmt.Errorf("%s: %w", msg, cause)
+	}
+	return errors.New(msg)
+}
+
+// readLenEnc читает целое переменной длины; null — значение NULL (0xfb).
+func readLenEnc(b []byte) (v uint64, null bool, n int) {
+	if len(b) == 0 {
+		return 0, false, 0
+	}
+	switch b[0] {
+	case 0xfb:
+		return 0, true, 1
+	case 0xfc:
+		n = 3
+	case 0xfd:
+		n = 4
+	case 0xfe:
+		n = 9
+	default:
+		return uint64(b[0]), false, 1
+	}
+	if len(b) < n {
+		return 0, false, 0
+	}
+	for i := n - 1; i >= 1; i-- {
+		v = v<<8 | uint64(b[i])
+	}
+	return v, false, n
+}
+
+// readLenEncString читает строку с длиной переменной длины.
+func readLenEncString(b []byte) (s []byte, null bool, n int, err error) {
+	l, null, n := readLenEnc(b)
+	if n == 0 || uint64(len(b)-n) < l {
+		return nil, false, 0, errMySQLPacket
+	}
+	return b[n : n+int(l)], null, n + int(l), nil
+}
+
+// query отправляет COM_QUERY и читает ответ: OK-пакет или набор строк.
+func (c *mysqlConn) query(q string) (*mysqlRows, driver.Result, error) {
+	if c.broken {
+		return nil, nil, driver.ErrBadConn
+	}
+	c.seq = 0
+	if err := c.writePacket(append([]byte{0x03}, q...)); err != nil {
+		return nil, nil, driver.ErrBadConn
+	}
+	pkt, err := c.readPacket()
+	if err != nil {
+		return nil, nil, err
+	}
+	switch pkt[0] {
+	case 0x00:
+		affected, _, n := readLenEnc(pkt[1:])
+		insertID, _, _ := readLenEnc(pkt[1+n:])
+		return nil, mysqlResult{int64(affected), int64(insertID)}, nil
+	case 0xff:
+		return nil, nil, parseMySQLError(pkt)
+	case 0xfb:
+		c.broken = true
+		return nil, nil, fmt.Errorf("MySQL: LOAD DATA LOCAL не поддерживается")
+	}
+	count, _, _ := readLenEnc(pkt)
+	rows := &mysqlRows{}
+	for i := uint64(0); i < count; i++ {
+		def, err := c.readPacket()
+		if err != nil {
+			return nil, nil, err
+		}
+		// catalog, schema, table, org_table, name, …
+		var name []byte
+		for f, off := 0, 0; f < 5; f++ {
+			s, _, n, err := readLenEncString(def[off:])
+			if err != nil {
+				c.broken = true
+				return nil, nil, err
+			}
+			name, off = s, off+n
+		}
+		rows.cols = append(rows.cols, string(name))
+	}
+	if pkt, err = c.readPacket(); err != nil {
+		return nil, nil, err
+	}
+	if pkt[0] != 0xfe {
+		c.broken = true
+		return nil, nil, errMySQLPacket
+	}
+	for {
+		pkt, err := c.readPacket()
+		if err != nil {
+			return nil, nil, err
+		}
+		if pkt[0] == 0xfe && len(pkt) < 9 {
+			return rows, nil, nil
+		}
+		if pkt[0] == 0xff {
+			return nil, nil, parseMySQLError(pkt)
+		}
+		row := make([]driver.Value, count)
+		for i, off := 0, 0; i < len(row); i++ {
+			s, null, n, err := readLenEncString(pkt[off:])
+			if err != nil {
+				c.broken = true
+				return nil, nil, err
+			}
+			if !null {
+				row[i] = append([]byte{}, s...)
+			}
+			off += n
+		}
+		rows.rows = append(rows.rows, row)
+	}
+}
+
+// withDeadline ограничивает обмен с сервером сроком ctx.
+func (c *mysqlConn) withDeadline(ctx context.Context) func() {
+	if dl, ok := ctx.Deadline(); ok {
+		c.nc.SetDeadline(dl)
+		return func() { c.nc.SetDeadline(time.Time{}) }
+	}
+	return func() {}
+}
+
+func (c *mysqlConn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	q, err := mysqlInterpolate(query, args, c.noBackslash)
+	if err != nil {
+		return nil, err
+	}
+	defer c.withDeadline(ctx)()
+	_, res, err := c.query(q)
+	if err == nil && res == nil {
+		res = mysqlResult{}
+	}
+	return res, err
+}
+
+func (c *mysqlConn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	q, err := mysqlInterpolate(query, args, c.noBackslash)
+	if err != nil {
+		return nil, err
+	}
+	defer c.withDeadline(ctx)()
+	rows, _, err := c.query(q)
+	if err == nil && rows == nil {
+		rows = &mysqlRows{}
+	}
+	return rows, err
+}
+
+func (c *mysqlConn) Prepare(query string) (driver.Stmt, error) {
+	return &mysqlStmt{c: c, query: query}, nil
+}
+
+func (c *mysqlConn) Begin() (driver.Tx, error) {
+	if _, _, err := c.query("START TRANSACTION"); err != nil {
+		return nil, err
+	}
+	return mysqlTx{c}, nil
+}
+
+func (c *mysqlConn) Close() error {
+	if !c.broken {
+		c.seq = 0
+		c.writePacket([]byte{0x01}) // COM_QUIT
+	}
+	return c.nc.Close()
+}
+
+// IsValid — database/sql не возвращает в пул соединение с оборванным обменом.
+func (c *mysqlConn) IsValid() bool {
+	return !c.broken
+}
+
+type mysqlTx struct{ c *mysqlConn }
+
+func (tx mysqlTx) Commit() error {
+	_, _, err := tx.c.query("COMMIT")
+	return err
+}
+
+func (tx mysqlTx) Rollback() error {
+	_, _, err := tx.c.query("ROLLBACK")
+	return err
+}
+
+// mysqlStmt — «подготовленный» запрос: параметры подставляются при выполнении.
+type mysqlStmt struct {
+	c     *mysqlConn
+	query string
+}
+
+func (s *mysqlStmt) Close() error  { return nil }
+func (s *mysqlStmt) NumInput() int { return -1 }
+
+func (s *mysqlStmt) Exec(args []driver.Value) (driver.Result, error) {
+	return s.c.ExecContext(context.Background(), s.query, namedValues(args))
+}
+
+func (s *mysqlStmt) Query(args []driver.Value) (driver.Rows, error) {
+	return s.c.QueryContext(context.Background(), s.query, namedValues(args))
+}
+
+func namedValues(args []driver.Value) []driver.NamedValue {
+	out := make([]driver.NamedValue, len(args))
+	for i, v := range args {
+		out[i] = driver.NamedValue{Ordinal: i + 1, Value: v}
+	}
+	return out
+}
+
+type mysqlResult struct{ affected, insertID int64 }
+
+func (r mysqlResult) LastInsertId() (int64, error) { return r.insertID, nil }
+func (r mysqlResult) RowsAffected() (int64, error) { return r.affected, nil }
+
+// mysqlRows — результат SELECT, прочитанный целиком (autodeploy читает
+// только короткие списки).
+type mysqlRows struct {
+	cols []string
+	rows [][]driver.Value
+	pos  int
+}
+
+func (r *mysqlRows) Columns() []string { return r.cols }
+func (r *mysqlRows) Close() error      { return nil }
+
+func (r *mysqlRows) Next(dest []driver.Value) error {
+	if r.pos >= len(r.rows) {
+		return io.EOF
+	}
+	copy(dest, r.rows[r.pos])
+	r.pos++
+	return nil
+}
+
+// mysqlInterpolate подставляет параметры вместо «?» вне строк и имён в кавычках.
+func mysqlInterpolate(query string, args []driver.NamedValue, noBackslash bool) (string, error) {
+	if len(args) == 0 {
+		return query, nil
+	}
+	var b strings.Builder
+	n := 0
+	var quote byte
+	for i := 0; i < len(query); i++ {
+		ch := query[i]
+		switch {
+		case quote != 0:
+			if ch == quote {
+				quote = 0
+			} else if ch == '\\' && quote != '`' && !noBackslash && i+1 < len(query) {
+				b.WriteByte(ch)
+				i++
+				ch = query[i]
+			}
+		case ch == '\'' || ch == '"' || ch == '`':
+			quote = ch
+		case ch == '?':
+			if n >= len(args) {
+				return "", fmt.Errorf("MySQL: в запросе больше «?», чем параметров (%d)", len(args))
+			}
+			lit, err := mysqlLiteral(args[n].Value, noBackslash)
+			if err != nil {
+				return "", err
+			}
+			b.WriteString(lit)
+			n++
+			continue
+		}
+		b.WriteByte(ch)
+	}
+	if n != len(args) {
+		return "", fmt.Errorf("MySQL: параметров %d, а «?» в запросе %d", len(args), n)
+	}
+	return b.String(), nil
+}
+
+// mysqlLiteral — значение параметра как литерал SQL.
+func mysqlLiteral(v driver.Value, noBackslash bool) (string, error) {
+	switch v := v.(type) {
+	case nil:
+		return "NULL", nil
+	case int64:
+		return strconv.FormatInt(v, 10), nil
+	case float64:
+		return strconv.FormatFloat(v, 'g', -1, 64), nil
+	case bool:
+		if v {
+			return "1", nil
+		}
+		return "0", nil
+	case string:
+		return mysqlQuote(v, noBackslash), nil
+	case []byte:
+		return mysqlQuote(string(v), noBackslash), nil
+	case time.Time:
+		return "'" + v.Format("2006-01-02 15:04:05.999999") + "'", nil
+	}
+	return "", fmt.Errorf("MySQL: неподдерживаемый тип параметра %T", v)
+}
+
+// mysqlQuote — строка в одинарных кавычках. В UTF-8 байты экранируемых
+// символов не встречаются внутри многобайтных, поэтому идём по байтам.
+func mysqlQuote(s string, noBackslash bool) string {
+	var b strings.Builder
+	b.WriteByte('\'')
+	for i := 0; i < len(s); i++ {
+		ch := s[i]
+		if noBackslash {
+			if ch == '\'' {
+				b.WriteByte('\'')
+			}
+			b.WriteByte(ch)
+			continue
+		}
+		switch ch {
+		case 0:
+			b.WriteString(`\0`)
+		case '\n':
+			b.WriteString(`\n`)
+		case '\r':
+			b.WriteString(`\r`)
+		case '\\':
+			b.WriteString(`\\`)
+		case '\'':
+			b.WriteString(`\'`)
+		case '"':
+			b.WriteString(`\"`)
+		case 0x1a:
+			b.WriteString(`\Z`)
+		default:
+			b.WriteByte(ch)
+		}
+	}
+	b.WriteByte('\'')
+	return b.String()
+}
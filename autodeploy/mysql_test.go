@@ -0,0 +1,169 @@
+package main
+
+import (
+	"database/sql/driver"
+	"encoding/binary"
+	"io"
+	"net"
+	"strings"
+	"testing"
+	"time"
+)
+
+// ------------------------------
+// Клиент MySQL (раздел (29)): экранирование и вход
+// ------------------------------
+
+func TestMySQLQuote(t *testing.T) {
+	tests := []struct {
+		in          string
+		noBackslash bool
+		want        string
+	}{
+		{"plain", false, `'plain'`},
+		{"it's", false, `'it\'s'`},
+		{`say "hi"`, false, `'say \"hi\"'`},
+		{`C:\dir\`, false, `'C:\\dir\\'`},
+		{"a\x00b", false, `'a\0b'`},
+		{"line\nbreak\r", false, `'line\nbreak\r'`},
+		{"ctrl\x1az", false, `'ctrl\Zz'`},
+		{"x' OR 1=1 -- ", false, `'x\' OR 1=1 -- '`},
+		{"пароль'ё", false, `'пароль\'ё'`},
+		// NO_BACKSLASH_ESCAPES: \ — обычный символ, кавычка удваивается
+		{"it's", true, `'it''s'`},
+		{`C:\dir\`, true, `'C:\dir\'`},
+		{`\'; DROP DATABASE x; --`, true, `'\''; DROP DATABASE x; --'`},
+		{"a\x00b\n", true, "'a\x00b\n'"},
+	}
+	for _, tt := range tests {
+		if got := mysqlQuote(tt.in, tt.noBackslash); got != tt.want {
+			t.Errorf("mysqlQuote(%q, %v) = %s, ожидалось %s", tt.in, tt.noBackslash, got, tt.want)
+		}
+	}
+}
+
+func TestMySQLInterpolate(t *testing.T) {
+	named := func(args ...driver.Value) []driver.NamedValue {
+		return namedValues(args)
+	}
+	tests := []struct {
+		query       string
+		args        []driver.NamedValue
+		noBackslash bool
+		want        string
+	}{
+		{"SELECT 1", nil, false, "SELECT 1"},
+		{"CREATE USER ?@'localhost' IDENTIFIED BY ?", named("wp_user", "p'w\\d"), false,
+			`CREATE USER 'wp_user'@'localhost' IDENTIFIED BY 'p\'w\\d'`},
+		{"CREATE USER ?@'localhost' IDENTIFIED BY ?", named("wp_user", "p'w\\d"), true,
+			`CREATE USER 'wp_user'@'localhost' IDENTIFIED BY 'p''w\d'`},
+		// «?» в строках и именах в кавычках — не параметр
+		{"SELECT '?', \"?\", `a?b`, ?", named(int64(7)), false, "SELECT '?', \"?\", `a?b`, 7"},
+		{"SELECT 'it''s ?', ?", named("x"), false, "SELECT 'it''s ?', 'x'"},
+		{"SELECT 'it''s ?', ?", named("x"), true, "SELECT 'it''s ?', 'x'"},
+		// \' внутри строки не закрывает её, если \ — экранирующий символ
+		{`SELECT 'a\'?', ?`, named("x"), false, `SELECT 'a\'?', 'x'`},
+		{"SELECT ?, ?, ?, ?", named(nil, true, 1.5, time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC)), false,
+			"SELECT NULL, 1, 1.5, '2024-05-01 10:00:00'"},
+		{"SELECT ?", named([]byte("b'")), false, `SELECT 'b\''`},
+	}
+	for _, tt := range tests {
+		got, err := mysqlInterpolate(tt.query, tt.args, tt.noBackslash)
+		if err != nil || got != tt.want {
+			t.Errorf("mysqlInterpolate(%q, noBackslash=%v) = %s, %v; ожидалось %s", tt.query, tt.noBackslash, got, err, tt.want)
+		}
+	}
+	for _, tt := range []struct {
+		query string
+		args  []driver.NamedValue
+	}{
+		{"SELECT ?, ?", named("a")},
+		{"SELECT ?", named("a", "b")},
+		{"SELECT '?'", named("a")},
+		{"SELECT ?", named(struct{}{})},
+	} {
+		if got, err := mysqlInterpolate(tt.query, tt.args, false); err == nil {
+			t.Errorf("mysqlInterpolate(%q, %d параметров) = %s, ожидалась ошибка", tt.query, len(tt.args), got)
+		}
+	}
+}
+
+// mysqlTestServer — сервер MySQL на другом конце net.Pipe: шлёт приветствие
+// (status — флаги статуса), читает ответ клиента и дальше шлёт пакеты
+// replies, читая ответ клиента после каждого AuthSwitchRequest.
+func mysqlTestServer(t *testing.T, status uint16, replies ...[]byte) *mysqlConn {
+	t.Helper()
+	client, server := net.Pipe()
+	t.Cleanup(func() { client.Close(); server.Close() })
+	client.SetDeadline(time.Now().Add(5 * time.Second))
+	go func() {
+		seq := byte(0)
+		write := func(payload []byte) {
+			hdr := []byte{byte(len(payload)), byte(len(payload) >> 8), byte(len(payload) >> 16), seq}
+			server.Write(append(hdr, payload...))
+			seq++
+		}
+		read := func() {
+			var hdr [4]byte
+			if _, err := io.ReadFull(server, hdr[:]); err != nil {
+				return
+			}
+			io.ReadFull(server, make([]byte, int(hdr[0])|int(hdr[1])<<8|int(hdr[2])<<16))
+			seq = hdr[3] + 1
+		}
+		// протокол 10, версия, id, соль, 0, возможности, кодировка, статус, возможности
+		greeting := append([]byte{10}, "10.11.6-MariaDB\x00"...)
+		greeting = append(greeting, 1, 0, 0, 0)
+		greeting = append(greeting, "saltsalt"...)
+		greeting = append(greeting, 0, 0xff, 0xf7, 45)
+		greeting = binary.LittleEndian.AppendUint16(greeting, status)
+		greeting = binary.LittleEndian.AppendUint16(greeting, 0x0008) // CLIENT_PLUGIN_AUTH
+		write(greeting)
+		read()
+		for _, r := range replies {
+			write(r)
+			if r[0] == 0xfe {
+				read()
+			}
+		}
+	}()
+	return &mysqlConn{nc: client}
+}
+
+// mysqlErrPacket — ERR-пакет с кодом code.
+func mysqlErrPacket(code uint16, msg string) []byte {
+	return append(binary.LittleEndian.AppendUint16([]byte{0xff}, code), "#28000"+msg...)
+}
+
+func TestMySQLHandshake(t *testing.T) {
+	ok := []byte{0x00, 0, 0, 2, 0, 0, 0}
+	tests := []struct {
+		name    string
+		replies [][]byte
+		want    string // подстрока ошибки; пусто — вход успешен
+	}{
+		{"unix_socket", [][]byte{ok}, ""},
+		{"switch на unix_socket", [][]byte{append([]byte{0xfe}, "unix_socket\x00"...), ok}, ""},
+		{"caching_sha2 по кешу", [][]byte{{0x01, 0x03}, ok}, ""},
+		{"нужен пароль native", [][]byte{
+			append([]byte{0xfe}, "mysql_native_password\x00saltsaltsaltsaltsalt\x00"...),
+			mysqlErrPacket(1045, "Access denied for user 'root'@'localhost' (using password: NO)"),
+		}, "MySQL требует пароль для root@localhost (плагин mysql_native_password)"},
+		{"нужен пароль caching_sha2", [][]byte{{0x01, 0x04}}, "(плагин caching_sha2_password)"},
+		{"другая ошибка", [][]byte{mysqlErrPacket(1040, "Too many connections")}, "MySQL 1040"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := mysqlTestServer(t, mysqlStatusNoBackslashes, tt.replies...)
+			err := c.handshake("root")
+			switch {
+			case tt.want == "" && err != nil:
+				t.Fatalf("handshake: %v", err)
+			case tt.want == "" && !c.noBackslash:
+				t.Error("флаг NO_BACKSLASH_ESCAPES из статуса сервера не прочитан")
+			case tt.want != "" && (err == nil || !strings.Contains(err.Error(), tt.want)):
+				t.Errorf("ошибка %v, ожидалось %q", err, tt.want)
+			}
+		})
+	}
+}
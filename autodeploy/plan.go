@@ -0,0 +1,326 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// ------------------------------
+// (18) Команда `autodeploy plan <папка>` (сухой прогон)
+// ------------------------------
+
+// cmdPlan показывает, что демон сделает с папкой folderName из WATCH_DIR:
+// та же разборка суффикса и манифеста, тот же список шагов и тот же поиск
+// зоны в Cloudflare (только GET-запросы), но без изменений на сервере.
+func cmdPlan(args []string) int {
+	if len(args) != 1 {
+		fmt.Fprintln(os.Stderr, "Использование: autodeploy plan <папка>")
+		return 2
+	}
+	folderName := args[0]
+	realdom, idx, ok := parseFolderName(folderName)
+	if !ok || realdom == "" {
+		fmt.Printf("Папка %s не соответствует статусам 0..7, m и 777 — демон её пропустит.\n", folderName)
+		return 1
+	}
+	domain, err := normalizeDomain(realdom)
+	if err != nil {
+		fmt.Printf("Имя домена: %v\n", err)
+		fmt.Printf("Деплоя не будет: папка будет переименована в %s_%s (%s).\n", realdom, ErrDomainName.Code, ErrDomainName.Name)
+		return 1
+	}
+	if domain != realdom {
+		fmt.Printf("Домен %s => %s (punycode)\n", realdom, domain)
+	}
+	realdom = domain
+	if _, err := os.Stat(filepath.Join(WATCH_DIR, folderName)); err != nil {
+		fmt.Printf("[WARN] Папки %s нет в %s: файлы сайта и манифест не проверены.\n", folderName, WATCH_DIR)
+	}
+	if idx == "777" {
+		planRemoval(realdom, folderName)
+		return 0
+	}
+
+	d := newDeployment(realdom, idx, folderName)
+	m, manifestPath, err := siteManifestOf(d)
+	if err != nil {
+		fmt.Printf("Манифест: %v\n", err)
+		fmt.Printf("Деплоя не будет: папка будет переименована в %s_%s (%s).\n", realdom, ErrManifest.Code, ErrManifest.Name)
+		return 1
+	}
+	if m != nil {
+		m.apply(d)
+	}
+	fmt.Printf("План деплоя %s (папка %s):\n", d.Domain, folderName)
+	if manifestPath != "" {
+		fmt.Printf("  манифест:  %s (будет удалён из папки после чтения)\n", manifestPath)
+	}
+	fmt.Printf("  статус:    %s => type=%s, ssl=%s, www=%s, php=%s\n", d.Idx, d.SiteType, d.SSLNeeded, d.UseWww, d.phpVersion())
+	if len(d.Aliases) > 0 {
+		fmt.Printf("  алиасы:    %s\n", strings.Join(d.Aliases, " "))
+	}
+	if len(d.Redirects) > 0 {
+		fmt.Printf("  редиректы: %s (301 на %s)\n", strings.Join(d.Redirects, " "), d.primaryHost())
+	}
+	fmt.Printf("  webroot:   %s\n", d.webroot())
+	if old, err := loadDeployment(d.Domain); err == nil {
+		fmt.Printf("  журнал:    уже есть (статус %s, шаг %s) — будет заменён новым деплоем\n", old.Status, old.Step)
+	}
+
+	var cf cfAccount
+	var cfErr error
+	if useCloudflare {
+		acc, err := checkDomainCloudflare(d.Domain, false)
+		if err != nil {
+			cfErr = err
+		} else {
+			cf = acc
+			d.CFZoneID, d.CFZoneName, d.CFEmail, d.CFAPIKey = acc.ZoneID, acc.ZoneName, acc.Email, acc.APIKey
+		}
+	}
+
+	fmt.Println("Шаги:")
+	for i, st := range deploySteps(d) {
+		lines, failErr := planStep(d, st.name, cf, cfErr)
+		fmt.Printf("  %2d. %-20s %s\n", i+1, st.name, lines[0])
+		for _, l := range lines[1:] {
+			fmt.Printf("      %-20s %s\n", "", l)
+		}
+		if failErr != nil {
+			code := errorCodeOf(failErr)
+			fmt.Printf("Деплой остановится на шаге %s: ошибка %s (%s): %v\n", st.name, code.Code, code.Name, failErr)
+			fmt.Printf("Откат вернёт сервер к исходному состоянию, папка будет переименована в %s_%s.\n", d.Domain, code.Code)
+			fmt.Printf("Что делать: %s\n", code.Remedy)
+			return 1
+		}
+	}
+	fmt.Printf("Итог: сайт %s будет развернут, папка останется как %s.\n", d.Domain, d.Domain)
+	for _, path := range hookPaths(HOOK_POST_DEPLOY, d.Domain) {
+		fmt.Printf("После деплоя: хук %s %s\n", HOOK_POST_DEPLOY, path)
+	}
+	return 0
+}
+
+// planStep описывает шаг name деплоя d. Второе значение — ошибка, на которой
+// шаг гарантированно упадёт (её видно без выполнения шага).
+func planStep(d *deployment, name string, cf cfAccount, cfErr error) ([]string, error) {
+	src := filepath.Join(WATCH_DIR, d.Folder)
+	switch name {
+	case "rename":
+		if d.Folder == d.Domain {
+			return []string{"папка уже называется " + d.Domain}, nil
+		}
+		return []string{fmt.Sprintf("переименовать %s -> %s", src, d.webroot())}, nil
+	case "snapshot":
+		return []string{fmt.Sprintf("копия загруженных файлов в %s (для отката)", d.snapshotPath())}, nil
+	case "cloudflare":
+		if !useCloudflare {
+			return []string{"пропуск: " + CLOUDFLARE_TXT + " не задан"}, nil
+		}
+		if cfErr != nil {
+			return []string{"проверка зоны и DNS в Cloudflare"}, cfErr
+		}
+		if cf.NoRecord {
+			return []string{fmt.Sprintf("зона %s (%s) в аккаунте %s, создать A-запись %s -> %s", cf.ZoneName, d.CFZoneID, d.CFEmail, d.Domain, SERVER_IP)}, nil
+		}
+		return []string{fmt.Sprintf("зона %s (%s) в аккаунте %s, DNS указывает на %s", cf.ZoneName, d.CFZoneID, d.CFEmail, SERVER_IP)}, nil
+	case "cf_aliases":
+		if !useCloudflare {
+			return []string{"пропуск: " + CLOUDFLARE_TXT + " не задан"}, nil
+		}
+		var lines []string
+		for _, name := range d.extraNames() {
+			lines = append(lines, fmt.Sprintf("зона и DNS алиаса %s в Cloudflare (должен указывать на %s)", name, SERVER_IP))
+		}
+		return lines, nil
+	case "cf_ssl_flexible":
+		return []string{planCFPatch(d, "ssl", "flexible")}, nil
+	case "stub":
+		conf := filepath.Join(NGINX_AVAILABLE, d.Domain)
+		lines := []string{
+			fmt.Sprintf("затычка nginx %s (+ симлинк в %s), nginx -t и reload", conf, NGINX_ENABLED),
+			fmt.Sprintf("самоподписанный сертификат /etc/nginx/self-signed/%s.crt и .key", d.Domain),
+		}
+		if planExists(conf) {
+			lines = append(lines, "текущий конфиг "+conf+" будет сохранён для отката")
+		}
+		return lines, nil
+	case "check_text":
+		return []string{fmt.Sprintf("проверочный index.php, %d попыток GET https://%s", TEXT_CHECK_ATTEMPTS, d.Domain)}, nil
+	case "passwords":
+		return []string{"сгенерировать пароль БД (9 символов) и администратора (12 символов)"}, nil
+	case "static_index":
+		return []string{"записать index.php с 'IN'"}, nil
+	case "wp_download":
+		if planExists(filepath.Join(src, "wp-includes", "version.php")) {
+			return []string{"пропуск: ядро WordPress уже загружено"}, nil
+		}
+		locale := d.WPLocale
+		if locale == "" {
+			locale = "по умолчанию"
+		}
+		return []string{fmt.Sprintf("wp core download в %s (локаль: %s)", d.webroot(), locale)}, nil
+	case "wp_database":
+		dbName, dbUser, err := planDBName(d)
+		if err != nil {
+			return nil, newDeployError(ErrMySQL, "mysql: %v", err)
+		}
+		lines := []string{fmt.Sprintf("база `%s` и пользователь '%s'@'localhost', GRANT ALL; запись в реестр %s", dbName, dbUser, SITES_FILE)}
+		dbExists, err := mysqlDatabaseExists(dbName)
+		if err != nil {
+			return lines, newDeployError(ErrMySQL, "mysql: %v", err)
+		}
+		if dbExists {
+			lines = append(lines, "база уже существует: будет использована и не удалится при откате")
+		}
+		userExists, err := mysqlUserExists(dbUser)
+		if err != nil {
+			return lines, newDeployError(ErrMySQL, "mysql: %v", err)
+		}
+		if userExists {
+			lines = append(lines, "пользователь уже существует: пароль будет заменён")
+		}
+		return lines, nil
+	case "wp_config":
+		if planExists(filepath.Join(src, "wp-config.php")) {
+			return []string{"пропуск: загружен свой wp-config.php"}, nil
+		}
+		dbName, dbUser, err := planDBName(d)
+		if err != nil {
+			return nil, newDeployError(ErrMySQL, "mysql: %v", err)
+		}
+		return []string{fmt.Sprintf("wp config create (dbname=%s, dbuser=%s, dbhost=localhost)", dbName, dbUser)}, nil
+	case "wp_install":
+		url := "https://" + d.Domain
+		if d.UseWww == "yes" {
+			url = "https://www." + d.Domain
+		}
+		return []string{
+			fmt.Sprintf("wp core install --url=%s, администратор %s", url, d.Domain),
+			"реквизиты дописываются в " + WP_LOG,
+		}, nil
+	case "permissions":
+		return []string{fmt.Sprintf("chown www-data, 755/644 на %s", d.webroot())}, nil
+	case "certbot":
+		domains := append([]string{d.Domain}, d.extraNames()...)
+		live := filepath.Join("/etc/letsencrypt/live", d.Domain)
+		lines := []string{
+			"certbot certonly --nginx -d " + strings.Join(domains, " -d "),
+			fmt.Sprintf("сертификат: %s/fullchain.pem, ключ: %s/privkey.pem", live, live),
+		}
+		if planExists(live) {
+			lines = append(lines, "сертификат уже есть — certbot переиспользует его")
+		}
+		return lines, nil
+	case "final_config":
+		tpl := finalTemplateFor(d)
+		conf := filepath.Join(NGINX_AVAILABLE, d.Domain)
+		text, err := renderTemplate(d, tpl)
+		if err != nil {
+			return []string{"шаблон " + tpl}, newDeployError(ErrTemplate, "шаблон %s: %v", tpl, err)
+		}
+		lines := []string{fmt.Sprintf("шаблон %s -> %s, nginx -t и reload", tpl, conf)}
+		for _, sn := range serverNameRe.FindAllString(text, -1) {
+			lines = append(lines, strings.Join(strings.Fields(sn), " "))
+		}
+		if d.SSLNeeded == "yes" {
+			lines = append(lines, "удалить самоподписанный сертификат затычки")
+		}
+		return lines, nil
+	case "cf_ssl_final":
+		mode := "flexible"
+		if d.SSLNeeded == "yes" {
+			mode = "full"
+		}
+		return []string{planCFPatch(d, "ssl", mode)}, nil
+	case "cf_defaults":
+		if !useCloudflare {
+			return []string{"пропуск: Cloudflare не настроен"}, nil
+		}
+		var lines []string
+		for _, st := range cfDefaultSettings {
+			lines = append(lines, planCFPatch(d, st.Key, st.Value))
+		}
+		return lines, nil
+	}
+	if strings.HasPrefix(name, "hook_") {
+		return planHooks(d, strings.ReplaceAll(strings.TrimPrefix(name, "hook_"), "_", "-")), nil
+	}
+	return []string{"шаг " + name}, nil
+}
+
+// planHooks перечисляет хуки фазы в порядке запуска.
+func planHooks(d *deployment, phase string) []string {
+	var lines []string
+	for _, path := range hookPaths(phase, d.Domain) {
+		lines = append(lines, "хук "+path)
+	}
+	if len(lines) == 0 {
+		lines = append(lines, "хуков нет")
+	}
+	return lines
+}
+
+// planCFPatch описывает PATCH настройки зоны (сам запрос не отправляется).
+func planCFPatch(d *deployment, key, value string) string {
+	if !useCloudflare {
+		return fmt.Sprintf("пропуск: Cloudflare не настроен (%s=%s)", key, value)
+	}
+	if !d.ownCFZone() {
+		return fmt.Sprintf("пропуск: зона %s общая с другими сайтами (%s=%s)", d.CFZoneName, key, value)
+	}
+	return fmt.Sprintf("PATCH zones/%s/settings/%s = %s", d.CFZoneID, key, value)
+}
+
+// planRemoval печатает, что удалит папка с суффиксом _777.
+func planRemoval(realdom, folderName string) {
+	fmt.Printf("План удаления сайта %s (папка %s):\n", realdom, folderName)
+	for _, path := range hookPaths(HOOK_PRE_DELETE, realdom) {
+		fmt.Printf("  хук %s %s (ненулевой код отменит удаление)\n", HOOK_PRE_DELETE, path)
+	}
+	fmt.Printf("  сохранить копию (файлы, дамп базы, nginx, сертификаты) в %s/<время>, хранится %d дн.\n",
+		filepath.Join(TRASH_DIR, realdom), TRASH_GRACE_DAYS)
+	dbName, dbUser, err := siteDatabase(realdom)
+	if err != nil {
+		fmt.Printf("  реестр сайтов: %v\n", err)
+		dbName, dbUser = realdom, realdom
+	}
+	dbState, userState := "нет", "нет"
+	if ok, err := mysqlDatabaseExists(dbName); err != nil {
+		dbState = "MySQL: " + err.Error()
+	} else if ok {
+		dbState = "есть"
+	}
+	if ok, err := mysqlUserExists(dbUser); err != nil {
+		userState = "MySQL: " + err.Error()
+	} else if ok {
+		userState = "есть"
+	}
+	fmt.Printf("  DROP DATABASE `%s`  [%s]\n", dbName, dbState)
+	fmt.Printf("  DROP USER '%s'@'localhost'  [%s]\n", dbUser, userState)
+	for _, path := range removalPaths(realdom, folderName) {
+		state := "нет"
+		if fi, err := os.Lstat(path); err == nil {
+			state = "есть"
+			if fi.IsDir() {
+				files := 0
+				filepath.Walk(path, func(_ string, info os.FileInfo, err error) error {
+					if err == nil && !info.IsDir() {
+						files++
+					}
+					return nil
+				})
+				state = fmt.Sprintf("есть, файлов: %d", files)
+			}
+		}
+		fmt.Printf("  удалить %s  [%s]\n", path, state)
+	}
+	fmt.Println("  перезагрузить nginx")
+}
+
+func planExists(path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"fmt"
+	"testing"
+)
+
+// ------------------------------
+// Публичные суффиксы (раздел (27)): зоны Cloudflare для поддоменов
+// ------------------------------
+
+func TestZoneCandidates(t *testing.T) {
+	tests := []struct {
+		host string
+		want []string
+	}{
+		{"example.com", []string{"example.com"}},
+		{"Shop.Example.COM.", []string{"shop.example.com", "example.com"}},
+		{"a.shop.example.com", []string{"a.shop.example.com", "shop.example.com", "example.com"}},
+		{"example.co.uk", []string{"example.co.uk"}},
+		{"shop.example.co.uk", []string{"shop.example.co.uk", "example.co.uk"}},
+		{"xn--e1afmkfd.xn--p1ai", []string{"xn--e1afmkfd.xn--p1ai"}},
+		// *.ck: любой домен второго уровня — суффикс, www.ck — исключение
+		{"shop.example.ck", []string{"shop.example.ck"}},
+		{"www.ck", []string{"www.ck"}},
+		{"a.www.ck", []string{"a.www.ck", "www.ck"}},
+		// !city.kobe.jp при *.kobe.jp
+		{"city.kobe.jp", []string{"city.kobe.jp"}},
+		{"x.city.kobe.jp", []string{"x.city.kobe.jp", "city.kobe.jp"}},
+		// Частные суффиксы (github.io и т. п.) в список не входят: это обычные
+		// домены, и зоной может оказаться github.io
+		{"user.github.io", []string{"user.github.io", "github.io"}},
+		// Неизвестный TLD — правило по умолчанию «*»
+		{"shop.example.internal", []string{"shop.example.internal", "example.internal"}},
+		// Сам публичный суффикс — зон нет
+		{"co.uk", nil},
+		{"com", nil},
+		{"example.ck", nil},
+	}
+	for _, tt := range tests {
+		if got := zoneCandidates(tt.host); fmt.Sprint(got) != fmt.Sprint(tt.want) {
+			t.Errorf("zoneCandidates(%q) = %v, ожидалось %v", tt.host, got, tt.want)
+		}
+	}
+	for _, tt := range []struct {
+		host string
+		want bool
+	}{
+		{"example.com", false},
+		{"www.example.com", true},
+		{"example.co.uk", false},
+		{"shop.example.co.uk", true},
+		{"co.uk", false},
+	} {
+		if got := isSubdomain(tt.host); got != tt.want {
+			t.Errorf("isSubdomain(%q) = %v, ожидалось %v", tt.host, got, tt.want)
+		}
+	}
+}
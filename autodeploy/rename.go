@@ -0,0 +1,364 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// ------------------------------
+// (25) Команда `autodeploy rename <старый> <новый>`
+// ------------------------------
+
+// Переименование — это деплой нового домена со своими шагами (renameSteps):
+// журнал заводится на новый домен с RenamedFrom = старый, поэтому
+// переименование так же продолжается после рестарта, откатывается при
+// ошибке и повторяется `autodeploy retry <новый>`. Старый сайт работает до
+// шага move_site; его конфиг nginx, сертификаты и журнал удаляются последним
+// шагом, когда новый домен уже обслуживается.
+
+// cmdRename запускает переименование сайта old в new.
+func cmdRename(args []string) int {
+	if len(args) != 2 {
+		fmt.Fprintln(os.Stderr, "Использование: autodeploy rename <старый домен> <новый домен>")
+		return 2
+	}
+	old, ok := domainArg(args[0])
+	if !ok {
+		return 2
+	}
+	domain, ok := domainArg(args[1])
+	if !ok {
+		return 2
+	}
+	if domain == old {
+		fmt.Fprintf(os.Stderr, "[ERROR] %s уже называется %s\n", args[0], args[1])
+		return 2
+	}
+	// Блокировки берём в одном порядке, чтобы два встречных rename не ждали
+	// друг друга. Журнал старого домена читаем только под блокировкой: иначе
+	// его могут успеть удалить (_777) или передеплоить
+	first, second := old, domain
+	if second < first {
+		first, second = second, first
+	}
+	for _, name := range []string{first, second} {
+		unlock, err := lockDomain(name)
+		if err != nil {
+			log.Printf("[ERROR] Не смогли заблокировать домен %s: %v", name, err)
+			return 1
+		}
+		defer unlock()
+	}
+	src, err := loadDeployment(old)
+	if err != nil {
+		log.Printf("[ERROR] Нет журнала деплоя для %s: %v", old, err)
+		return 1
+	}
+	if src.Status != "done" {
+		log.Printf("[ERROR] Деплой %s не завершён (статус %s), переименовывать нельзя.", old, src.Status)
+		return 1
+	}
+	if d, err := loadDeployment(domain); err == nil {
+		log.Printf("[ERROR] У %s уже есть журнал деплоя (статус %s). Незавершённое переименование продолжает autodeploy retry %s.",
+			domain, d.Status, domain)
+		return 1
+	}
+	free := &siteBundle{Domain: domain}
+	if err := free.checkFree(); err != nil {
+		log.Printf("[ERROR] Не переименовываем %s в %s: %v", old, domain, err)
+		return 1
+	}
+	d := *src
+	if d.SiteType == "wp" && d.DBName == "" {
+		// Базу и пользователя шаг rename_db переименует под новый домен
+		if d.DBName, d.DBUser, err = siteDatabase(old); err != nil {
+			log.Printf("[ERROR] Реестр сайтов: %v", err)
+			return 1
+		}
+	}
+	// Копия на случай, если что-то пойдёт не так и после отката
+	key, err := createBackupLocked(newBackupStore(), old, "cli:rename")
+	if err != nil {
+		log.Printf("[ERROR] Резервная копия %s перед переименованием не создана: %v", old, err)
+		return 1
+	}
+	log.Printf("[INFO] Резервная копия %s: %s", old, key)
+	d.Domain = domain
+	d.RenamedFrom = old
+	d.Done, d.Undo = nil, nil
+	d.CFZoneID, d.CFZoneName, d.CFEmail, d.CFAPIKey = "", "", "", ""
+	d.Started = time.Now()
+	d.Trigger = "cli:rename " + old
+	log.Printf("[INFO] Переименовываем сайт %s -> %s...", old, domain)
+	if !runDeployment(&d) {
+		return 1
+	}
+	return 0
+}
+
+// renameSteps — шаги переименования d.RenamedFrom -> d.Domain.
+func renameSteps(d *deployment) []deployStep {
+	steps := []deployStep{
+		{"cloudflare", stepCloudflareCheck},
+		{"cf_ssl_flexible", stepCFSSLFlexible},
+		{"stub", stepStub},
+	}
+	if d.SSLNeeded == "yes" {
+		steps = append(steps, deployStep{"certbot", stepCertbot})
+	}
+	steps = append(steps, deployStep{"move_site", stepMoveSite})
+	if d.SiteType == "wp" {
+		steps = append(steps,
+			deployStep{"rename_db", stepRenameDB},
+			deployStep{"search_replace", stepSearchReplace},
+		)
+	}
+	steps = append(steps,
+		deployStep{"permissions", stepPermissions},
+		deployStep{"final_config", stepFinalConfig},
+		deployStep{"cf_ssl_final", stepCFSSLFinal},
+		deployStep{"cf_defaults", stepCFDefaults},
+		deployStep{"remove_old", stepRemoveOld},
+	)
+	return steps
+}
+
+// Перенос папки сайта под новое имя
+func stepMoveSite(d *deployment) error {
+	from := filepath.Join(WATCH_DIR, d.Folder)
+	if from == d.webroot() {
+		return nil
+	}
+	d.addUndo("move_files", d.webroot(), from)
+	err := os.Rename(from, d.webroot())
+	d.audit("files_move", d.webroot(), "из "+from, err)
+	if err != nil {
+		return err
+	}
+	d.Folder = d.Domain
+	// Пароль basic auth (копия для тестов) — в файле с именем домена
+	if data, err := os.ReadFile(htpasswdPath(d.RenamedFrom)); err == nil {
+		d.addUndo("remove_file", htpasswdPath(d.Domain), "")
+		if err := os.WriteFile(htpasswdPath(d.Domain), data, 0640); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// База и пользователь MySQL переходят к новому домену под новым именем
+// (freeDBName, раздел (30)): переименовываем их и правим wp-config.php
+func stepRenameDB(d *deployment) error {
+	// Новое имя закрепляется за доменом в реестре сразу: после рестарта
+	// шаг продолжит с тем же именем
+	var to string
+	created := false
+	err := updateSites(func(sites map[string]*siteRecord) error {
+		if r := sites[d.Domain]; r != nil && r.DBName != "" {
+			to = r.DBName
+			return nil
+		}
+		name, err := freeDBName(d.Domain, sites)
+		if err != nil {
+			return err
+		}
+		to, created = name, true
+		sites[d.Domain] = &siteRecord{Domain: d.Domain, Type: d.SiteType, DBName: name, DBUser: name,
+			Created: time.Now(), Updated: time.Now()}
+		return nil
+	})
+	if err != nil {
+		return newDeployError(ErrMySQL, "реестр сайтов: %v", err)
+	}
+	if created {
+		d.addUndo("forget_site", d.Domain, "")
+	}
+	if to == d.DBName {
+		return nil
+	}
+	// Старой базы нет: либо свой wp-config.php, либо шаг прервался после
+	// переименования (тогда уже есть новая) — повтор его доделает
+	exists := false
+	for _, name := range []string{d.DBName, to} {
+		ok, err := mysqlDatabaseExists(name)
+		if err != nil {
+			return newDeployError(ErrMySQL, "mysql: %v", err)
+		}
+		exists = exists || ok
+	}
+	if !exists {
+		d.logf("INFO", "Базы %s нет (свой wp-config.php?), не переименовываем.", d.DBName)
+		return nil
+	}
+	// Цель и данные отката — «база пользователь»: в именах баз пробелов нет
+	d.addUndo("rename_db", to+" "+to, d.DBName+" "+d.DBUser)
+	err = renameDatabase(d, d.DBName, d.DBUser, to, to)
+	d.audit("db_rename", to, "база "+d.DBName+" и пользователь '"+d.DBUser+"'@'localhost'", err)
+	if err != nil {
+		return newDeployError(ErrMySQL, "переименование базы: %v", err)
+	}
+	return nil
+}
+
+// renameDatabase переносит таблицы базы fromDB в базу toDB, переименовывает
+// пользователя fromUser в toUser и прописывает новые имена в wp-config.php
+// сайта d (и в d.DBName, d.DBUser). Повторный вызов (и вызов после
+// частичного выполнения) безопасен, поэтому им же пользуется откат.
+func renameDatabase(d *deployment, fromDB, fromUser, toDB, toUser string) error {
+	tables, err := mysqlColumn("SELECT TABLE_NAME FROM information_schema.TABLES WHERE TABLE_SCHEMA = ?", fromDB)
+	if err != nil {
+		return err
+	}
+	if err := mysqlExec("CREATE DATABASE IF NOT EXISTS " + mysqlIdent(toDB)); err != nil {
+		return err
+	}
+	var pairs []string
+	for _, t := range tables {
+		pairs = append(pairs, mysqlIdent(fromDB)+"."+mysqlIdent(t)+" TO "+mysqlIdent(toDB)+"."+mysqlIdent(t))
+	}
+	if len(pairs) > 0 {
+		if err := mysqlExec("RENAME TABLE " + strings.Join(pairs, ", ")); err != nil {
+			return err
+		}
+	}
+	userExists, err := mysqlUserExists(fromUser)
+	if err != nil {
+		return err
+	}
+	if userExists && fromUser != toUser {
+		if err := mysqlRun("RENAME USER ?@'localhost' TO ?@'localhost'", fromUser, toUser); err != nil {
+			return err
+		}
+	}
+	// Пользователя может не быть (его удалили руками или повторный вызов после
+	// сбоя): GRANT на несуществующего пользователя при NO_AUTO_CREATE_USER —
+	// ошибка, а без него — новый пользователь без пароля.
+	toExists := userExists
+	if !userExists {
+		if toExists, err = mysqlUserExists(toUser); err != nil {
+			return err
+		}
+	}
+	if toExists {
+		if err := mysqlRun("GRANT ALL ON "+mysqlIdent(toDB)+".* TO ?@'localhost'", toUser); err != nil {
+			return err
+		}
+	} else {
+		d.logf("WARN", "Пользователя MySQL %s нет: права на базу %s не выданы.", toUser, toDB)
+	}
+	if err := mysqlDropDatabase(fromDB); err != nil {
+		return err
+	}
+	// Права на старую базу переехали вместе с пользователем; их может и не быть
+	// (MySQL 1141 — такого права нет)
+	if toExists {
+		var me *mysqlError
+		err := mysqlRun("REVOKE ALL PRIVILEGES ON "+mysqlIdent(fromDB)+".* FROM ?@'localhost'", toUser)
+		if err != nil && !(errors.As(err, &me) && me.Code == 1141) {
+			d.logf("WARN", "Не смогли отозвать у %s права на старую базу %s: %v", toUser, fromDB, err)
+		}
+	}
+	if err := mysqlExec("FLUSH PRIVILEGES"); err != nil {
+		return err
+	}
+	d.DBName, d.DBUser = toDB, toUser
+	root := filepath.Join(WATCH_DIR, d.Folder)
+	if _, err := os.Stat(filepath.Join(root, "wp-config.php")); err != nil {
+		return nil
+	}
+	for _, kv := range [][2]string{{"DB_NAME", toDB}, {"DB_USER", toUser}} {
+		if err := d.run("wp", "config", "set", kv[0], kv[1], "--path="+root, "--allow-root"); err != nil {
+			return newDeployError(ErrWPCLI, "wp config set %s: %v", kv[0], err)
+		}
+	}
+	return nil
+}
+
+// Замена адресов старого домена в базе (wp search-replace учитывает
+// сериализованные данные PHP)
+func stepSearchReplace(d *deployment) error {
+	d.addUndo("search_replace", d.Domain, d.RenamedFrom)
+	err := searchReplaceDomain(d, d.RenamedFrom, d.Domain)
+	d.audit("db_search_replace", d.Domain, "//"+d.RenamedFrom+" -> //"+d.Domain, err)
+	return err
+}
+
+// searchReplaceDomain заменяет адреса //from и //www.from на to. Меняются
+// только адреса: логин администратора и почта остаются прежними.
+func searchReplaceDomain(d *deployment, from, to string) error {
+	return searchReplaceURLs(d, "//"+from, "//"+to, "//www."+from, "//www."+to)
+}
+
+// searchReplaceURLs выполняет wp search-replace для пар (что, на что).
+func searchReplaceURLs(d *deployment, pairs ...string) error {
+	for i := 0; i+1 < len(pairs); i += 2 {
+		err := d.run("wp", "search-replace", pairs[i], pairs[i+1], "--all-tables", "--precise",
+			"--skip-columns=guid", "--path="+filepath.Join(WATCH_DIR, d.Folder), "--allow-root")
+		if err != nil {
+			return newDeployError(ErrWPCLI, "wp search-replace %s: %v", pairs[i], err)
+		}
+	}
+	return nil
+}
+
+// Удаляем следы старого домена: конфиг nginx, сертификаты, журнал. Реквизиты
+// в WP_LOG переписываются на новый домен. Новый сайт к этому шагу уже
+// работает, поэтому неудавшееся удаление не откатывает переименование, а
+// попадает в лог предупреждением со списком того, что осталось.
+func stepRemoveOld(d *deployment) error {
+	old := d.RenamedFrom
+	var left []string
+	for _, path := range removalPaths(old, old)[1:] {
+		if _, err := os.Lstat(path); err != nil {
+			continue
+		}
+		err := os.RemoveAll(path)
+		d.audit(removalAuditAction(path), path, "переименование в "+d.Domain, err)
+		if err != nil {
+			d.logf("WARN", "Не смогли удалить %s: %v", path, err)
+			left = append(left, path)
+		}
+	}
+	for _, ext := range []string{".crt", ".key"} {
+		path := filepath.Join("/etc/nginx/self-signed", old+ext)
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			d.logf("WARN", "Не смогли удалить %s: %v", path, err)
+			left = append(left, path)
+		}
+	}
+	if data, err := os.ReadFile(WP_LOG); err == nil {
+		lines := strings.SplitAfter(string(data), "\n")
+		for i, line := range lines {
+			f := strings.Split(line, "|")
+			if len(f) == 5 && f[0] == old {
+				f[0] = d.Domain
+				if d.DBName != "" {
+					f[3] = d.DBName
+				}
+				lines[i] = strings.Join(f, "|")
+			}
+		}
+		err = os.WriteFile(WP_LOG, []byte(strings.Join(lines, "")), 0644)
+		d.audit("credentials_update", WP_LOG, old+" -> "+d.Domain, err)
+		if err != nil {
+			d.logf("WARN", "Реквизиты %s в %s не переписаны на %s: %v", old, WP_LOG, d.Domain, err)
+		}
+	}
+	if err := forgetSite(old); err != nil {
+		d.logf("WARN", "Не смогли убрать %s из реестра сайтов: %v", old, err)
+	}
+	if err := reloadNginx(); err != nil {
+		d.logf("WARN", "После удаления конфига %s nginx не перезагружен: %v", old, err)
+	}
+	if len(left) > 0 {
+		d.logf("WARN", "Сайт %s переименован в %s, но от старого домена остались: %s. Удалите их вручную.", old, d.Domain, strings.Join(left, ", "))
+		return nil
+	}
+	d.logf("INFO", "Сайт %s переименован в %s.", old, d.Domain)
+	return nil
+}
@@ -0,0 +1,181 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// ------------------------------
+// (17) Откат неудавшегося деплоя
+// ------------------------------
+
+// undoAction — действие отката, которое шаг регистрирует перед тем, как
+// что-то изменить. Хранится в журнале, поэтому откат работает и после
+// рестарта демона. При ошибке действия выполняются в обратном порядке.
+type undoAction struct {
+	Kind   string `json:"kind"` // restore_files | restore_nginx | remove_file | drop_db | drop_user | remove_cert | remove_line | move_files | remove_files | rename_db | search_replace | forget_site
+	Step   string `json:"step"`
+	Target string `json:"target"`
+	Data   string `json:"data,omitempty"` // путь резервной копии или удаляемая строка
+}
+
+// addUndo регистрирует действие отката и сразу сохраняет журнал: если демон
+// упадёт посреди шага, откат всё равно будет знать, что убирать.
+func (d *deployment) addUndo(kind, target, data string) {
+	d.Undo = append(d.Undo, undoAction{Kind: kind, Step: d.Step, Target: target, Data: data})
+	d.save()
+}
+
+// undoNginx запоминает, какой конфиг nginx был у домена до деплоя (обычно
+// никакого), чтобы откат вернул именно его. Регистрируется один раз.
+func (d *deployment) undoNginx() {
+	for _, u := range d.Undo {
+		if u.Kind == "restore_nginx" {
+			return
+		}
+	}
+	backup := ""
+	if data, err := os.ReadFile(filepath.Join(NGINX_AVAILABLE, d.Domain)); err == nil {
+		backup = filepath.Join(SNAPSHOT_DIR, d.Domain+".nginx")
+		os.MkdirAll(SNAPSHOT_DIR, 0700)
+		if err := os.WriteFile(backup, data, 0644); err != nil {
+			d.logf("WARN", "Не смогли сохранить конфиг nginx %s: %v", d.Domain, err)
+		}
+	}
+	d.addUndo("restore_nginx", d.Domain, backup)
+}
+
+// snapshotPath — копия загруженных файлов сайта на время деплоя.
+func (d *deployment) snapshotPath() string {
+	return filepath.Join(SNAPSHOT_DIR, d.Domain)
+}
+
+// Копия загруженных файлов (cp -a сохраняет права и владельца), чтобы
+// откат вернул папку ровно такой, какой её загрузили
+func stepSnapshot(d *deployment) error {
+	os.RemoveAll(d.snapshotPath())
+	if err := os.MkdirAll(SNAPSHOT_DIR, 0700); err != nil {
+		return err
+	}
+	if err := d.run("cp", "-a", d.webroot(), d.snapshotPath()); err != nil {
+		return fmt.Errorf("копия файлов сайта: %v", err)
+	}
+	d.addUndo("restore_files", d.webroot(), d.snapshotPath())
+	return nil
+}
+
+// rollback выполняет действия отката в обратном порядке. После него на
+// сервере нет следов деплоя, а retry начнёт с первого шага. Настройки зоны
+// в Cloudflare не откатываются: это не состояние сервера.
+func (d *deployment) rollback() {
+	reload := false
+	for i := len(d.Undo) - 1; i >= 0; i-- {
+		u := d.Undo[i]
+		d.logf("INFO", "Откат %s (шаг %s): %s", u.Kind, u.Step, u.Target)
+		var err error
+		switch u.Kind {
+		case "restore_files":
+			if _, err = os.Stat(u.Data); err == nil {
+				os.RemoveAll(u.Target)
+				if err = runCmd("cp", "-a", u.Data, u.Target); err == nil {
+					os.RemoveAll(u.Data)
+				}
+			}
+		case "restore_nginx":
+			os.Remove(filepath.Join(NGINX_ENABLED, u.Target))
+			os.Remove(filepath.Join(NGINX_AVAILABLE, u.Target))
+			if u.Data != "" {
+				conf := filepath.Join(NGINX_AVAILABLE, u.Target)
+				if err = runCmd("cp", "-a", u.Data, conf); err == nil {
+					err = os.Symlink(conf, filepath.Join(NGINX_ENABLED, u.Target))
+					os.Remove(u.Data)
+				}
+			}
+			reload = true
+		case "remove_file":
+			if err = os.Remove(u.Target); os.IsNotExist(err) {
+				err = nil
+			}
+		case "drop_db":
+			err = mysqlDropDatabase(u.Target)
+		case "drop_user":
+			err = mysqlDropUser(u.Target)
+		case "remove_cert":
+			os.RemoveAll(filepath.Join("/etc/letsencrypt/live", u.Target))
+			os.RemoveAll(filepath.Join("/etc/letsencrypt/archive", u.Target))
+			os.Remove(filepath.Join("/etc/letsencrypt/renewal", u.Target+".conf"))
+		case "remove_line":
+			err = removeLine(u.Target, u.Data)
+		case "remove_files":
+			err = os.RemoveAll(u.Target)
+		case "move_files":
+			if err = os.Rename(u.Target, u.Data); err == nil || os.IsNotExist(err) {
+				d.Folder, err = filepath.Base(u.Data), nil
+			}
+		case "rename_db":
+			toDB, toUser, _ := strings.Cut(u.Target, " ")
+			fromDB, fromUser, _ := strings.Cut(u.Data, " ")
+			err = renameDatabase(d, toDB, toUser, fromDB, fromUser)
+		case "search_replace":
+			err = searchReplaceDomain(d, u.Target, u.Data)
+		case "forget_site":
+			err = forgetSite(u.Target)
+		default:
+			err = fmt.Errorf("неизвестное действие отката")
+		}
+		d.audit(undoAuditActions[u.Kind], u.Target, "откат шага "+u.Step, err)
+		if err != nil {
+			d.logf("WARN", "Откат %s (%s) не удался: %v", u.Kind, u.Target, err)
+		}
+	}
+	if reload {
+		if err := reloadNginx(); err != nil {
+			d.logf("WARN", "После отката nginx не перезагружен: %v", err)
+		}
+	}
+	d.Undo = nil
+	d.Done = nil
+	d.save()
+}
+
+// commit вызывается после успешного деплоя: откат больше не нужен, удаляем
+// копию загруженных файлов и резервную копию конфига nginx.
+func (d *deployment) commit() {
+	for _, u := range d.Undo {
+		if (u.Kind == "restore_files" || u.Kind == "restore_nginx") && u.Data != "" {
+			os.RemoveAll(u.Data)
+		}
+	}
+	d.Undo = nil
+}
+
+// undoAuditActions — как действия отката называются в журнале аудита.
+var undoAuditActions = map[string]string{
+	"restore_files":  "files_restore",
+	"restore_nginx":  "nginx_restore",
+	"remove_file":    "file_remove",
+	"drop_db":        "db_drop",
+	"drop_user":      "db_user_drop",
+	"remove_cert":    "cert_delete",
+	"remove_line":    "line_remove",
+	"move_files":     "files_move",
+	"remove_files":   "files_remove",
+	"rename_db":      "db_rename",
+	"search_replace": "db_search_replace",
+	"forget_site":    "site_forget",
+}
+
+// removeLine удаляет из файла path первую строку, равную line (с \n).
+func removeLine(path, line string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	idx := strings.Index(string(data), line)
+	if idx < 0 {
+		return nil
+	}
+	return os.WriteFile(path, append(data[:idx:idx], data[idx+len(line):]...), 0644)
+}
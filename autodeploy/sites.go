@@ -0,0 +1,279 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// ------------------------------
+// (30) Реестр сайтов: базы и пользователи MySQL
+// ------------------------------
+
+// Раньше база и пользователь MySQL назывались как домен. Такое имя упирается в
+// пределы MySQL (пользователь — до 32 символов, в MariaDB — до 80), а точки в
+// нём неудобны. Новые сайты получают имя siteDBName, а реестр SITES_FILE
+// хранит, какая база у какого домена: по нему её находят удаление, корзина,
+// резервные копии, клон и переименование (оно переименовывает базу под
+// новый домен). Сайтам без записи в реестре (развёрнутым до него)
+// соответствует база с именем домена.
+
+// siteRecord — запись реестра сайтов.
+type siteRecord struct {
+	Domain  string    `json:"domain"`
+	Type    string    `json:"type"`
+	DBName  string    `json:"db_name,omitempty"`
+	DBUser  string    `json:"db_user,omitempty"`
+	Created time.Time `json:"created"`
+	Updated time.Time `json:"updated"`
+}
+
+// loadSites читает реестр; нет файла — реестр пуст.
+func loadSites() (map[string]*siteRecord, error) {
+	sites := map[string]*siteRecord{}
+	data, err := os.ReadFile(SITES_FILE)
+	if os.IsNotExist(err) {
+		return sites, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var list []*siteRecord
+	if err := json.Unmarshal(data, &list); err != nil {
+		return nil, fmt.Errorf("повреждён реестр %s: %v", SITES_FILE, err)
+	}
+	for _, r := range list {
+		sites[r.Domain] = r
+	}
+	return sites, nil
+}
+
+// updateSites меняет реестр под блокировкой: fn правит записи по доменам.
+func updateSites(fn func(sites map[string]*siteRecord) error) error {
+	if err := os.MkdirAll(filepath.Dir(SITES_FILE), 0700); err != nil {
+		return err
+	}
+	f, err := os.OpenFile(SITES_FILE+".lock", os.O_CREATE|os.O_RDWR, 0600)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	if err := syscall.Flock(int(f.Fd()), syscall.LOCK_EX); err != nil {
+		return err
+	}
+	defer syscall.Flock(int(f.Fd()), syscall.LOCK_UN)
+	sites, err := loadSites()
+	if err != nil {
+		return err
+	}
+	if err := fn(sites); err != nil {
+		return err
+	}
+	list := make([]*siteRecord, 0, len(sites))
+	for _, r := range sites {
+		list = append(list, r)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Domain < list[j].Domain })
+	data, err := json.MarshalIndent(list, "", "  ")
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(SITES_FILE, append(data, '\n'), 0600)
+}
+
+// recordSite записывает сайт d в реестр (или обновляет запись).
+func recordSite(d *deployment) error {
+	return updateSites(func(sites map[string]*siteRecord) error {
+		r := sites[d.Domain]
+		if r == nil {
+			r = &siteRecord{Domain: d.Domain, Created: time.Now()}
+			sites[d.Domain] = r
+		}
+		r.Type = d.SiteType
+		if d.DBName != "" {
+			r.DBName, r.DBUser = d.DBName, d.DBUser
+		}
+		r.Updated = time.Now()
+		return nil
+	})
+}
+
+// forgetSite удаляет сайт domain из реестра.
+func forgetSite(domain string) error {
+	return updateSites(func(sites map[string]*siteRecord) error {
+		delete(sites, domain)
+		return nil
+	})
+}
+
+// siteDatabase — база и пользователь MySQL развёрнутого сайта domain (есть
+// ли они в MySQL, не проверяется).
+func siteDatabase(domain string) (db, user string, err error) {
+	sites, err := loadSites()
+	if err != nil {
+		return "", "", err
+	}
+	if r := sites[domain]; r != nil && r.DBName != "" {
+		return r.DBName, r.DBUser, nil
+	}
+	return domain, domain, nil
+}
+
+// siteDBName — имя новой базы и пользователя для домена: начало домена в
+// [a-z0-9_] до 23 символов и 8 hex-символов sha256 — всего до 32 символов.
+// attempt > 0 даёт следующие варианты на случай коллизии.
+func siteDBName(domain string, attempt int) string {
+	var prefix strings.Builder
+	for i := 0; i < len(domain) && prefix.Len() < 23; i++ {
+		c := domain[i]
+		if c >= 'a' && c <= 'z' || c >= '0' && c <= '9' {
+			prefix.WriteByte(c)
+		} else {
+			prefix.WriteByte('_')
+		}
+	}
+	seed := domain
+	if attempt > 0 {
+		seed = fmt.Sprintf("%s#%d", domain, attempt)
+	}
+	sum := sha256.Sum256([]byte(seed))
+	return strings.TrimRight(prefix.String(), "_") + "_" + hex.EncodeToString(sum[:4])
+}
+
+// pickDB выбирает базу и пользователя для сайта domain: из реестра; у
+// сайта, развёрнутого до реестра, — базу с именем домена; иначе freeDBName.
+func pickDB(domain string, sites map[string]*siteRecord) (db, user string, err error) {
+	if r := sites[domain]; r != nil && r.DBName != "" {
+		return r.DBName, r.DBUser, nil
+	}
+	// База с именем домена может принадлежать переименованному сайту — тогда
+	// она записана в реестре за ним
+	if !dbNameTaken(domain, sites) {
+		if legacy, err := mysqlDatabaseExists(domain); err != nil {
+			return "", "", err
+		} else if legacy {
+			return domain, domain, nil
+		}
+	}
+	name, err := freeDBName(domain, sites)
+	return name, name, err
+}
+
+// dbNameTaken — записано ли имя name в реестре как база или пользователь.
+func dbNameTaken(name string, sites map[string]*siteRecord) bool {
+	for _, r := range sites {
+		if r.DBName == name || r.DBUser == name {
+			return true
+		}
+	}
+	return false
+}
+
+// freeDBName — первое свободное имя siteDBName для домена: не записано в
+// реестре и нет ни базы, ни пользователя с таким именем в MySQL.
+func freeDBName(domain string, sites map[string]*siteRecord) (string, error) {
+	for attempt := 0; attempt < 10; attempt++ {
+		name := siteDBName(domain, attempt)
+		if dbNameTaken(name, sites) {
+			continue
+		}
+		dbExists, err := mysqlDatabaseExists(name)
+		if err != nil {
+			return "", err
+		}
+		userExists, err := mysqlUserExists(name)
+		if err != nil {
+			return "", err
+		}
+		if !dbExists && !userExists {
+			return name, nil
+		}
+		log.Printf("[WARN] Имя базы %s для %s уже занято в MySQL, пробуем следующее", name, domain)
+	}
+	return "", fmt.Errorf("не нашли свободное имя базы для %s", domain)
+}
+
+// reserveDB закрепляет за сайтом d базу (d.DBName из журнала или pickDB) и
+// записывает её в реестр, чтобы параллельный деплой не выбрал то же имя.
+// created — записи о сайте в реестре до этого не было.
+func reserveDB(d *deployment) (created bool, err error) {
+	err = updateSites(func(sites map[string]*siteRecord) error {
+		if d.DBName == "" {
+			db, user, err := pickDB(d.Domain, sites)
+			if err != nil {
+				return err
+			}
+			d.DBName, d.DBUser = db, user
+		}
+		r := sites[d.Domain]
+		if r == nil {
+			r = &siteRecord{Domain: d.Domain, Created: time.Now()}
+			sites[d.Domain] = r
+			created = true
+		}
+		r.Type, r.DBName, r.DBUser, r.Updated = d.SiteType, d.DBName, d.DBUser, time.Now()
+		return nil
+	})
+	return created, err
+}
+
+// planDBName — какие базу и пользователя получит сайт d (для плана, реестр
+// не меняется).
+func planDBName(d *deployment) (db, user string, err error) {
+	if d.DBName != "" {
+		return d.DBName, d.DBUser, nil
+	}
+	sites, err := loadSites()
+	if err != nil {
+		return "", "", err
+	}
+	return pickDB(d.Domain, sites)
+}
+
+// cmdSites — `autodeploy sites`: реестр сайтов и сайты, развёрнутые до него.
+func cmdSites(args []string) int {
+	if len(args) != 0 {
+		fmt.Fprintln(os.Stderr, "Использование: autodeploy sites")
+		return 2
+	}
+	sites, err := loadSites()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "[ERROR]", err)
+		return 1
+	}
+	for _, d := range listDeployments() {
+		if d.Status == "done" && sites[d.Domain] == nil {
+			r := &siteRecord{Domain: d.Domain, Type: d.SiteType}
+			if d.SiteType == "wp" {
+				r.DBName, r.DBUser = d.Domain, d.Domain
+			}
+			sites[d.Domain] = r
+		}
+	}
+	domains := make([]string, 0, len(sites))
+	for domain := range sites {
+		domains = append(domains, domain)
+	}
+	sort.Strings(domains)
+	for _, domain := range domains {
+		r := sites[domain]
+		db := "-"
+		if r.DBName != "" {
+			db = fmt.Sprintf("база %s, пользователь '%s'@'localhost'", r.DBName, r.DBUser)
+		}
+		note := ""
+		if r.Created.IsZero() {
+			note = "  (нет в реестре, развёрнут раньше)"
+		}
+		fmt.Printf("%-30s %-6s %s%s\n", domain, r.Type, db, note)
+	}
+	return 0
+}
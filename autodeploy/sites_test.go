@@ -0,0 +1,80 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+// ------------------------------
+// Реестр сайтов (раздел (30)): имена баз
+// ------------------------------
+
+func TestSiteDBName(t *testing.T) {
+	valid := regexp.MustCompile(`^[a-z0-9][a-z0-9_]{0,22}_[0-9a-f]{8}$`)
+	long := strings.Repeat("very-long-company-name-", 3)
+	domains := []string{
+		"example.com",
+		"a.io",
+		"my-site.example.co.uk",
+		"xn--e1afmkfd.xn--p1ai",
+		long + "one.com",
+		long + "two.com",
+		strings.Repeat("a", 63) + ".com",
+		// префиксы совпадают: отличаются только хешем
+		"a-b.com",
+		"a.b.com",
+		"a_b.com",
+	}
+	seen := map[string]string{}
+	for _, domain := range domains {
+		for attempt := 0; attempt < 3; attempt++ {
+			name := siteDBName(domain, attempt)
+			if len(name) > 32 || !valid.MatchString(name) {
+				t.Errorf("siteDBName(%q, %d) = %q: не имя базы MySQL до 32 символов", domain, attempt, name)
+			}
+			if name != siteDBName(domain, attempt) {
+				t.Errorf("siteDBName(%q, %d) меняется от вызова к вызову", domain, attempt)
+			}
+			key := fmt.Sprintf("%s#%d", domain, attempt)
+			if prev, dup := seen[name]; dup {
+				t.Errorf("siteDBName: %s и %s дают одно имя %s", prev, key, name)
+			}
+			seen[name] = key
+		}
+	}
+	if got := siteDBName("example.com", 0); !strings.HasPrefix(got, "example_com_") {
+		t.Errorf("siteDBName(example.com) = %q, ожидался префикс example_com_", got)
+	}
+	if a, b := siteDBName(long+"one.com", 0), siteDBName(long+"two.com", 0); a[:23] != b[:23] {
+		t.Errorf("общий префикс длинных доменов обрезан по-разному: %q, %q", a, b)
+	}
+}
+
+func TestDBNameTaken(t *testing.T) {
+	name := siteDBName("new.com", 0)
+	sites := map[string]*siteRecord{
+		"old.com":    {Domain: "old.com", DBName: name, DBUser: name},
+		"other.com":  {Domain: "other.com", DBName: "other_db", DBUser: "other_user"},
+		"legacy.com": {Domain: "legacy.com"},
+	}
+	for _, tt := range []struct {
+		name string
+		want bool
+	}{
+		{name, true},
+		{"other_db", true},
+		{"other_user", true},
+		{siteDBName("new.com", 1), false},
+		{"legacy.com", false},
+	} {
+		if got := dbNameTaken(tt.name, sites); got != tt.want {
+			t.Errorf("dbNameTaken(%q) = %v, ожидалось %v", tt.name, got, tt.want)
+		}
+	}
+	// Сайт из реестра получает свою базу без обращения к MySQL
+	if db, user, err := pickDB("other.com", sites); err != nil || db != "other_db" || user != "other_user" {
+		t.Errorf("pickDB(other.com) = %q, %q, %v; ожидалось other_db, other_user", db, user, err)
+	}
+}
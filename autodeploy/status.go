@@ -0,0 +1,109 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// ------------------------------
+// (16) Файл статуса деплоя .autodeploy-status.json
+// ------------------------------
+
+// deployStatus — машиночитаемый статус деплоя. Пишется в папку сайта (её видно
+// по SFTP) и копией в STATUS_DIR/<домен>.json.
+type deployStatus struct {
+	Domain    string    `json:"domain"`
+	Folder    string    `json:"folder"`
+	State     string    `json:"state"` // running | failed | done
+	Step      string    `json:"step,omitempty"`
+	StepsDone []string  `json:"steps_done"`
+	Started   time.Time `json:"started"`
+	Updated   time.Time `json:"updated"`
+	Finished  time.Time `json:"finished,omitempty"`
+	ErrorCode string    `json:"error_code,omitempty"`
+	ErrorName string    `json:"error_name,omitempty"`
+	Problem   string    `json:"problem,omitempty"` // описание кода из каталога
+	Remedy    string    `json:"remedy,omitempty"`
+	Cause     string    `json:"cause,omitempty"` // конкретная причина
+	Output    string    `json:"output,omitempty"`
+}
+
+// writeStatus записывает файл статуса деплоя d.
+func (d *deployment) writeStatus() {
+	st := deployStatus{
+		Domain:    d.Domain,
+		Folder:    d.Folder,
+		State:     d.Status,
+		Step:      d.Step,
+		StepsDone: d.Done,
+		Started:   d.Started,
+		Updated:   time.Now(),
+		Finished:  d.Finished,
+		ErrorCode: d.ErrorCode,
+		Cause:     d.Error,
+		Output:    d.Output,
+	}
+	if d.ErrorCode != "" {
+		code := errCodeByCode(d.ErrorCode)
+		st.ErrorName, st.Problem, st.Remedy = code.Name, code.Description, code.Remedy
+	}
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(st); err != nil {
+		return
+	}
+	data := buf.Bytes()
+	if err := os.MkdirAll(STATUS_DIR, 0755); err == nil {
+		os.WriteFile(filepath.Join(STATUS_DIR, d.Domain+".json"), data, 0644)
+	}
+	// Папки может уже не быть (удалена вручную) — тогда только копия в STATUS_DIR
+	if dir := filepath.Join(WATCH_DIR, d.Folder); d.Folder != "" {
+		if fi, err := os.Stat(dir); err == nil && fi.IsDir() {
+			os.WriteFile(filepath.Join(dir, STATUS_FILE), data, 0644)
+		}
+	}
+}
+
+// tailBuffer хранит последние max байт всего, что в него записали.
+type tailBuffer struct {
+	max int
+	buf []byte
+}
+
+func (t *tailBuffer) Write(p []byte) (int, error) {
+	t.buf = append(t.buf, p...)
+	if len(t.buf) > t.max {
+		t.buf = t.buf[len(t.buf)-t.max:]
+	}
+	return len(p), nil
+}
+
+// run запускает команду шага деплоя как runCmd, но дополнительно сохраняет
+// последние 8 КБ её вывода в d.Output (пароли сайта в нём маскируются).
+func (d *deployment) run(name string, args ...string) error {
+	tail := &tailBuffer{max: 8192}
+	cmd := exec.Command(name, args...)
+	cmd.Stdout = io.MultiWriter(os.Stdout, tail)
+	cmd.Stderr = io.MultiWriter(os.Stderr, tail)
+	err := cmd.Run()
+	out := fmt.Sprintf("$ %s %s\n%s", name, strings.Join(args, " "), tail.buf)
+	if err != nil {
+		out += fmt.Sprintf("\n(%v)", err)
+	}
+	for _, secret := range []string{d.DBPass, d.AdminPass} {
+		if secret != "" {
+			out = strings.ReplaceAll(out, secret, "***")
+		}
+	}
+	d.Output = out
+	return err
+}
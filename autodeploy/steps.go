@@ -0,0 +1,690 @@
+package main
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/tls"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// ------------------------------
+// (12) Генерировать 9 символов
+// ------------------------------
+func generate9chars() (string, error) {
+	return randomPassword(9)
+}
+
+// randomPassword — n случайных символов [A-Za-z0-9] из crypto/rand.
+func randomPassword(n int) (string, error) {
+	const chars = "ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz0123456789"
+	out := make([]byte, 0, n)
+	b := make([]byte, 1)
+	for len(out) < n {
+		if _, err := rand.Read(b); err != nil {
+			return "", err
+		}
+		// 248 = 4*62: байты выше отбрасываем, чтобы символы были равновероятны
+		if b[0] < 248 {
+			out = append(out, chars[b[0]%62])
+		}
+	}
+	return string(out), nil
+}
+
+// htpasswdHash — хеш пароля для htpasswd (apr1). Пароль уходит в openssl
+// через stdin, а не аргументом: в списке процессов его не видно.
+func htpasswdHash(pass string) (string, error) {
+	if strings.ContainsAny(pass, "\r\n") {
+		return "", fmt.Errorf("перевод строки в пароле")
+	}
+	cmd := exec.Command("openssl", "passwd", "-apr1", "-stdin")
+	cmd.Stdin = strings.NewReader(pass + "\n")
+	var out bytes.Buffer
+	cmd.Stdout = &out
+	cmd.Stderr = os.Stderr
+	if err := cmd.Run(); err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(out.String()), nil
+}
+
+// ------------------------------
+// (13) TEXT_CHECK_ATTEMPTS попыток проверить текст
+// ------------------------------
+
+// checkTextAttempts вторым значением возвращает ответ последней попытки (для файла статуса).
+func checkTextAttempts(domain, txt string) (bool, string) {
+	attempt := 0
+	lastOutput := ""
+	for attempt < TEXT_CHECK_ATTEMPTS {
+		log.Printf("[INFO] Проверяем https://%s (попытка %d)...", domain, attempt+1)
+		checkOutput, err := fetchPage(fmt.Sprintf("https://%s", domain))
+		if err == nil && strings.Contains(checkOutput, txt) {
+			log.Printf("[INFO] Текст %s найден (попытка %d)!", txt, attempt+1)
+			sleepSec(3)
+			return true, checkOutput
+		}
+		lastOutput = checkOutput
+		if err != nil {
+			lastOutput = err.Error()
+		}
+		log.Printf("[WARN] Не нашли текст %s, ждём %d сек...", txt, TEXT_CHECK_SECONDS)
+		time.Sleep(time.Duration(TEXT_CHECK_SECONDS) * time.Second)
+		attempt++
+	}
+	return false, lastOutput
+}
+
+// textCheckClient не проверяет сертификат: до certbot сайт отвечает
+// самоподписанным (см. createStubConfig).
+var textCheckClient = &http.Client{
+	Timeout:   30 * time.Second,
+	Transport: &http.Transport{Proxy: http.ProxyFromEnvironment, TLSClientConfig: &tls.Config{InsecureSkipVerify: true}},
+}
+
+// fetchPage возвращает тело ответа на GET rawURL (не больше 1 МБ).
+func fetchPage(rawURL string) (string, error) {
+	resp, err := textCheckClient.Get(rawURL)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	return string(body), err
+}
+
+// ------------------------------
+// (14) Создать затычку с поддержкой 80 и 443 (с самоподписанным сертификатом)
+// ------------------------------
+// extra — алиасы сайта: затычка отвечает и на них, чтобы плагин nginx у certbot нашёл
+// server-блок для каждого имени в сертификате.
+func createStubConfig(domain string, extra ...string) error {
+	// Каталог для самоподписанных сертификатов
+	selfSignedDir := "/etc/nginx/self-signed"
+	os.MkdirAll(selfSignedDir, 0755)
+	certPath := filepath.Join(selfSignedDir, domain+".crt")
+	keyPath := filepath.Join(selfSignedDir, domain+".key")
+
+	// Если сертификата нет, генерируем его
+	if _, err := os.Stat(certPath); os.IsNotExist(err) {
+		subj := fmt.Sprintf("/CN=%s", domain)
+		cmd := exec.Command("openssl", "req", "-x509", "-nodes", "-days", "365", "-newkey", "rsa:2048",
+			"-keyout", keyPath, "-out", certPath, "-subj", subj)
+		if err := cmd.Run(); err != nil {
+			log.Printf("[ERROR] Не удалось создать самоподписанный сертификат для %s: %v", domain, err)
+		} else {
+			log.Printf("[INFO] Самоподписанный сертификат создан для %s", domain)
+		}
+	}
+
+	confpath := filepath.Join(NGINX_AVAILABLE, domain)
+	stub := fmt.Sprintf(`server {
+    listen 80;
+    server_name %[1]s;
+    return 301 https://$host$request_uri;
+}
+
+server {
+    listen 443 ssl;
+    server_name %[1]s;
+    root %[2]s;
+    index index.html index.php;
+
+    ssl_certificate %[3]s;
+    ssl_certificate_key %[4]s;
+
+    location / {
+        try_files $uri $uri/ @blank;
+    }
+
+    location @blank {
+        return 200 "";
+    }
+
+    location = /.autodeploy-status.json {
+        deny all;
+    }
+}
+`, strings.Join(append(stubNames(domain), extra...), " "), filepath.Join(WATCH_DIR, domain), certPath, keyPath)
+
+	if err := os.WriteFile(confpath, []byte(stub), 0644); err != nil {
+		log.Printf("[ERROR] Ошибка при создании затычки: %v", err)
+		return err
+	}
+	// Удаляем старую симлинк, если есть
+	os.Remove(filepath.Join(NGINX_ENABLED, domain))
+	_ = os.Symlink(confpath, filepath.Join(NGINX_ENABLED, domain))
+	if err := reloadNginx(); err != nil {
+		// Не оставляем сломанный конфиг: он мешает перезагрузке nginx для всех сайтов
+		os.Remove(filepath.Join(NGINX_ENABLED, domain))
+		os.Remove(confpath)
+		return err
+	}
+	log.Printf("[INFO] Создана затычка для %s", domain)
+	return nil
+}
+
+// stubNames — имена затычки: домен и www.домен (у поддомена www нет).
+func stubNames(domain string) []string {
+	if isSubdomain(domain) {
+		return []string{domain}
+	}
+	return []string{domain, "www." + domain}
+}
+
+// ------------------------------
+// (15) Шаги деплоя
+// ------------------------------
+
+type deployStep struct {
+	name string
+	run  func(d *deployment) error
+}
+
+// deploySteps возвращает шаги деплоя d по порядку. Имена шагов записываются
+// в журнал, поэтому их нельзя переименовывать.
+func deploySteps(d *deployment) []deployStep {
+	if d.RenamedFrom != "" {
+		return renameSteps(d)
+	}
+	if d.ClonedFrom != "" {
+		return cloneSteps(d)
+	}
+	steps := []deployStep{
+		{"rename", stepRename},
+		{"snapshot", stepSnapshot},
+	}
+	steps = append(steps, hookSteps(d, HOOK_PRE_CLOUDFLARE)...)
+	steps = append(steps, deployStep{"cloudflare", stepCloudflareCheck})
+	if len(d.extraNames()) > 0 {
+		steps = append(steps, deployStep{"cf_aliases", stepCFAliases})
+	}
+	steps = append(steps,
+		deployStep{"cf_ssl_flexible", stepCFSSLFlexible},
+		deployStep{"stub", stepStub},
+	)
+	steps = append(steps, hookSteps(d, HOOK_POST_STUB)...)
+	steps = append(steps,
+		deployStep{"check_text", stepCheckText},
+		deployStep{"passwords", stepPasswords},
+	)
+	if d.SiteType == "static" {
+		steps = append(steps, deployStep{"static_index", stepStaticIndex})
+	} else {
+		steps = append(steps,
+			deployStep{"wp_download", stepWPDownload},
+			deployStep{"wp_database", stepWPDatabase},
+			deployStep{"wp_config", stepWPConfig},
+			deployStep{"wp_install", stepWPInstall},
+		)
+	}
+	steps = append(steps, deployStep{"permissions", stepPermissions})
+	if d.SSLNeeded == "yes" {
+		steps = append(steps, hookSteps(d, HOOK_PRE_CERTBOT)...)
+		steps = append(steps, deployStep{"certbot", stepCertbot})
+	}
+	steps = append(steps,
+		deployStep{"final_config", stepFinalConfig},
+		deployStep{"cf_ssl_final", stepCFSSLFinal},
+		deployStep{"cf_defaults", stepCFDefaults},
+	)
+	return steps
+}
+
+// runDeployment выполняет ещё не выполненные шаги d, сохраняя журнал после
+// каждого. Возвращает true, если сайт развернут.
+func runDeployment(d *deployment) bool {
+	d.Status = "running"
+	d.FailedStep, d.Error, d.ErrorCode, d.Output = "", "", "", ""
+	d.Finished = time.Time{}
+	d.save()
+	emitEvent(d.event(EV_DEPLOY_STARTED))
+	d.logf("INFO", "site_type=%s, ssl_needed=%s, domain=%s", d.SiteType, d.SSLNeeded, d.Domain)
+	for _, st := range deploySteps(d) {
+		if d.isDone(st.name) {
+			d.logf("INFO", "Шаг %s для %s уже выполнен, пропускаем.", st.name, d.Domain)
+			continue
+		}
+		d.Step = st.name
+		d.Output = ""
+		d.save()
+		stepStart := time.Now()
+		err := st.run(d)
+		metrics.observeStep(st.name, time.Since(stepStart))
+		if err != nil {
+			code := errorCodeOf(err)
+			d.Status = "failed"
+			d.FailedStep = st.name
+			d.Error = err.Error()
+			d.save()
+			d.logf("ERROR", "Шаг %s для %s упал, откатываем деплой...", st.name, d.Domain)
+			d.rollback()
+			// Папка сайта с кодом ошибки в имени — сигнал для того, кто её загрузил.
+			// После отката переименования папка снова обслуживает старый домен,
+			// а папку клона откат удаляет — их не трогаем.
+			if d.RenamedFrom == "" && d.ClonedFrom == "" {
+				d.renameFailed(code.Code)
+			}
+			d.Finished = time.Now()
+			d.save()
+			logEvent(logEntry{Level: "ERROR", Domain: d.Domain, Step: st.name, ErrorCode: code.Code,
+				DurationMs: time.Since(stepStart).Milliseconds(), Output: d.Output},
+				"Деплой %s остановлен на шаге %s: ошибка %s (%s): %v. Что делать: %s",
+				d.Domain, st.name, code.Code, code.Name, err, code.Remedy)
+			emitEvent(d.event(EV_DEPLOY_FAILED))
+			metrics.deployment("failed", code.Code)
+			if _, err := d.runHooks(HOOK_ON_FAILURE); err != nil {
+				d.logf("WARN", "Хук %s для %s: %v", HOOK_ON_FAILURE, d.Domain, err)
+			}
+			return false
+		}
+		d.Done = append(d.Done, st.name)
+		d.save()
+		logEvent(logEntry{Level: "INFO", Domain: d.Domain, Step: st.name, DurationMs: time.Since(stepStart).Milliseconds()},
+			"Шаг %s для %s выполнен", st.name, d.Domain)
+		ev := d.event(EV_STEP_FINISHED)
+		ev.Step = st.name
+		ev.StepMs = time.Since(stepStart).Milliseconds()
+		emitEvent(ev)
+	}
+	d.Status = "done"
+	d.Step = ""
+	d.Output = ""
+	d.commit()
+	d.Finished = time.Now()
+	d.save()
+	if err := recordSite(d); err != nil {
+		d.logf("WARN", "Не смогли записать %s в реестр сайтов %s: %v", d.Domain, SITES_FILE, err)
+	}
+	logEvent(logEntry{Level: "INFO", Domain: d.Domain, DurationMs: d.Finished.Sub(d.Started).Milliseconds()},
+		"Сайт %s развернут успешно.", d.Domain)
+	emitEvent(d.event(EV_DEPLOY_SUCCEEDED))
+	metrics.deployment("succeeded", "")
+	if _, err := d.runHooks(HOOK_POST_DEPLOY); err != nil {
+		d.logf("WARN", "Хук %s для %s: %v", HOOK_POST_DEPLOY, d.Domain, err)
+	}
+	return true
+}
+
+// resumeDeployments ставит в очередь деплои, прерванные падением или рестартом демона.
+func resumeDeployments(jobs chan<- func()) {
+	for _, d := range listDeployments() {
+		if d.Status != "running" {
+			continue
+		}
+		domain := d.Domain
+		jobs <- func() {
+			unlock, err := lockDomain(domain)
+			if err != nil {
+				log.Printf("[ERROR] Не смогли заблокировать домен %s: %v", domain, err)
+				return
+			}
+			defer unlock()
+			// Пока ждали блокировку, домен мог обработать другой воркер
+			d, err := loadDeployment(domain)
+			if err != nil || d.Status != "running" {
+				return
+			}
+			log.Printf("[INFO] Продолжаем прерванный деплой %s (выполнено: %v)...", d.Domain, d.Done)
+			d.Trigger = "resume:" + journalPath(domain)
+			runDeployment(d)
+		}
+	}
+}
+
+// (D) Переименование папки
+func stepRename(d *deployment) error {
+	d.logf("INFO", "Переименовываем %s -> %s", d.Folder, d.Domain)
+	if d.Folder != d.Domain {
+		os.Rename(filepath.Join(WATCH_DIR, d.Folder), filepath.Join(WATCH_DIR, d.Domain))
+		d.Folder = d.Domain
+	}
+	return nil
+}
+
+// (E) Проверка домена через CloudFlare, если используется
+func stepCloudflareCheck(d *deployment) error {
+	if !useCloudflare {
+		d.logf("INFO", "Пропускаем проверку CloudFlare для %s, т.к. данные CloudFlare не заданы.", d.Domain)
+		return nil
+	}
+	acc, err := checkDomainCloudflare(d.Domain, true)
+	if err != nil {
+		d.logf("ERROR", "Cloudflare ошибка!")
+		return err
+	}
+	if acc.NoRecord {
+		d.audit("cf_dns_create", "zones/"+acc.ZoneID+"/dns_records", "A "+d.Domain+" -> "+SERVER_IP, nil)
+	}
+	d.CFZoneID = acc.ZoneID
+	d.CFZoneName = acc.ZoneName
+	d.CFEmail = acc.Email
+	d.CFAPIKey = acc.APIKey
+	return nil
+}
+
+// (E) Алиасы тоже должны быть в Cloudflare и указывать на этот сервер
+func stepCFAliases(d *deployment) error {
+	if !useCloudflare {
+		return nil
+	}
+	for _, name := range d.extraNames() {
+		acc, err := checkDomainCloudflare(name, true)
+		if err != nil {
+			return fmt.Errorf("алиас %s: %w", name, err)
+		}
+		if acc.NoRecord {
+			d.audit("cf_dns_create", "zones/"+acc.ZoneID+"/dns_records", "A "+name+" -> "+SERVER_IP, nil)
+		}
+	}
+	return nil
+}
+
+// (F) Установка SSL flexible через CloudFlare (если используется)
+func stepCFSSLFlexible(d *deployment) error {
+	if useCloudflare && d.skipCFZoneSettings("SSL flexible") {
+		return nil
+	}
+	if useCloudflare {
+		err := setCFSSLMode(d.cf(), "flexible")
+		d.audit("cf_patch", "zones/"+d.CFZoneID+"/settings/ssl", "value=flexible", err)
+	} else {
+		d.logf("INFO", "Пропускаем установку CloudFlare SSL (flexible) для %s.", d.Domain)
+	}
+	return nil
+}
+
+// (G) Создание затычки (с поддержкой 80 и 443)
+func stepStub(d *deployment) error {
+	d.undoNginx()
+	for _, ext := range []string{".crt", ".key"} {
+		path := filepath.Join("/etc/nginx/self-signed", d.Domain+ext)
+		if _, err := os.Stat(path); os.IsNotExist(err) {
+			d.addUndo("remove_file", path, "")
+		}
+	}
+	err := createStubConfig(d.Domain, d.extraNames()...)
+	d.audit("nginx_write", filepath.Join(NGINX_AVAILABLE, d.Domain), "затычка", err)
+	if err != nil {
+		return newDeployError(ErrStubNginx, "затычка для %s: %v", d.Domain, err)
+	}
+	return nil
+}
+
+// (H) Проверка 9-символьного текста
+func stepCheckText(d *deployment) error {
+	rtext, err := generate9chars()
+	if err != nil {
+		d.logf("ERROR", "Не смогли сгенерировать 9-символьный текст: %v", err)
+		return err
+	}
+	d.logf("INFO", "Случайный текст: %s", rtext)
+	os.MkdirAll(d.webroot(), 0755)
+	indexFile := filepath.Join(d.webroot(), "index.php")
+	os.WriteFile(indexFile, []byte(rtext), 0644)
+	fixPermissions(d.webroot())
+	found, lastOutput := checkTextAttempts(d.Domain, rtext)
+	if !found {
+		d.logf("ERROR", "Не нашли текст %s!", rtext)
+		d.Output = fmt.Sprintf("GET https://%s\n%s", d.Domain, lastOutput)
+		return newDeployError(ErrUnreachable, "проверочный текст %s не найден на https://%s", rtext, d.Domain)
+	}
+	d.logf("INFO", "Текст найден, удаляем проверочный index.php...")
+	os.Remove(indexFile)
+	return nil
+}
+
+// (J) Генерация паролей
+func stepPasswords(d *deployment) error {
+	var err error
+	if d.DBPass, err = randomPassword(9); err != nil {
+		return fmt.Errorf("пароль базы данных: %v", err)
+	}
+	if d.AdminPass, err = randomPassword(12); err != nil {
+		return fmt.Errorf("пароль администратора: %v", err)
+	}
+	return nil
+}
+
+// (L) Статический сайт
+func stepStaticIndex(d *deployment) error {
+	d.logf("INFO", "Статический => создаём index.php c 'IN'")
+	os.WriteFile(filepath.Join(d.webroot(), "index.php"), []byte("<?php echo 'IN'; ?>"), 0644)
+	return nil
+}
+
+// (L) WordPress: скачивание ядра
+func stepWPDownload(d *deployment) error {
+	d.logf("INFO", "Устанавливаем WordPress...")
+	if _, err := os.Stat(filepath.Join(d.webroot(), "wp-includes", "version.php")); err == nil {
+		d.logf("INFO", "Ядро WordPress уже есть в %s, не скачиваем.", d.webroot())
+		return nil
+	}
+	args := []string{"core", "download", "--path=" + d.webroot(), "--allow-root"}
+	if d.WPLocale != "" {
+		args = append(args, "--locale="+d.WPLocale)
+	}
+	if err := d.run("wp", args...); err != nil {
+		return newDeployError(ErrWPCLI, "wp core download: %v", err)
+	}
+	return nil
+}
+
+// (L) WordPress: база и пользователь MySQL
+func stepWPDatabase(d *deployment) error {
+	// Имя базы закрепляется за доменом в реестре сайтов (раздел (30))
+	created, err := reserveDB(d)
+	if err != nil {
+		return newDeployError(ErrMySQL, "реестр сайтов: %v", err)
+	}
+	if created {
+		d.addUndo("forget_site", d.Domain, "")
+	}
+	// Откатываем только то, что создаём сами: чужую базу трогать нельзя
+	dbExists, err := mysqlDatabaseExists(d.DBName)
+	if err != nil {
+		return newDeployError(ErrMySQL, "mysql: %v", err)
+	}
+	userExists, err := mysqlUserExists(d.DBUser)
+	if err != nil {
+		return newDeployError(ErrMySQL, "mysql: %v", err)
+	}
+	if !dbExists {
+		d.addUndo("drop_db", d.DBName, "")
+	}
+	if !userExists {
+		d.addUndo("drop_user", d.DBUser, "")
+	}
+	// IF NOT EXISTS + ALTER USER: шаг можно безопасно повторить при retry
+	err = mysqlCreateSite(d.DBName, d.DBUser, d.DBPass)
+	d.audit("db_create", d.DBName, "база "+d.DBName+" и пользователь '"+d.DBUser+"'@'localhost'", err)
+	if err != nil {
+		return newDeployError(ErrMySQL, "mysql: %v", err)
+	}
+	return nil
+}
+
+// (L) WordPress: wp-config.php
+func stepWPConfig(d *deployment) error {
+	cfgPath := filepath.Join(d.webroot(), "wp-config.php")
+	if _, err := os.Stat(cfgPath); err == nil {
+		// Загружен свой wp-config.php (или шаг повторяется) — не трогаем
+		d.logf("INFO", "%s уже есть, wp config create пропускаем.", cfgPath)
+		return nil
+	}
+	err := d.run("wp", "config", "create",
+		fmt.Sprintf("--dbname=%s", d.DBName),
+		fmt.Sprintf("--dbuser=%s", d.DBUser),
+		fmt.Sprintf("--dbpass=%s", d.DBPass),
+		"--dbhost=localhost",
+		"--path="+d.webroot(),
+		"--allow-root",
+	)
+	if err != nil {
+		return newDeployError(ErrWPCLI, "wp config create: %v", err)
+	}
+	fcfg, _ := os.OpenFile(cfgPath, os.O_APPEND|os.O_WRONLY, 0644)
+	if fcfg != nil {
+		fcfg.WriteString("define('FS_METHOD','direct');\n")
+		fcfg.Close()
+	}
+	return nil
+}
+
+// (L) WordPress: wp core install и запись реквизитов в WP_LOG
+func stepWPInstall(d *deployment) error {
+	siteURL := fmt.Sprintf("https://%s", d.Domain)
+	if d.UseWww == "yes" {
+		siteURL = fmt.Sprintf("https://www.%s", d.Domain)
+	}
+	title := d.WPTitle
+	if title == "" {
+		title = fmt.Sprintf("%s Site", d.Domain)
+	}
+	err := d.run("wp", "core", "install",
+		fmt.Sprintf("--url=%s", siteURL),
+		fmt.Sprintf("--title=%s", title),
+		fmt.Sprintf("--admin_user=%s", d.Domain),
+		fmt.Sprintf("--admin_password=%s", d.AdminPass),
+		fmt.Sprintf("--admin_email=admin@%s", d.Domain),
+		"--path="+d.webroot(),
+		"--allow-root",
+	)
+	if err != nil {
+		return newDeployError(ErrWPCLI, "wp core install: %v", err)
+	}
+	fwp, _ := os.OpenFile(WP_LOG, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if fwp != nil {
+		line := fmt.Sprintf("%s|%s|%s|%s|%s\n", d.Domain, d.Domain, d.AdminPass, d.DBName, d.DBPass)
+		d.addUndo("remove_line", WP_LOG, line)
+		fwp.WriteString(line)
+		fwp.Close()
+	}
+	return nil
+}
+
+// fixPermissions отдаёт каталог www-data и выставляет 755/644.
+func fixPermissions(dir string) {
+	runCmd("chown", "-R", "www-data:www-data", dir)
+	runCmd("find", dir, "-type", "d", "-exec", "chmod", "755", "{}", ";")
+	runCmd("find", dir, "-type", "f", "-exec", "chmod", "644", "{}", ";")
+}
+
+func stepPermissions(d *deployment) error {
+	fixPermissions(d.webroot())
+	return nil
+}
+
+// (M) Выпуск SSL через certbot
+func stepCertbot(d *deployment) error {
+	d.logf("INFO", "Выпускаем SSL (certbot) для %s...", d.Domain)
+	if _, err := os.Stat(filepath.Join("/etc/letsencrypt/live", d.Domain)); os.IsNotExist(err) {
+		d.addUndo("remove_cert", d.Domain, "")
+	}
+	// certonly: certbot только выпускает сертификат (проверка HTTP-01 через
+	// плагин nginx), а ставит его следующий шаг final_config — пишет конфиг
+	// по шаблону с SSL и перезагружает nginx под nginxMu. После продления
+	// nginx перезагружает certbot-renew.service (см. 6.go).
+	args := []string{"certonly", "--nginx", "-d", d.Domain}
+	for _, a := range d.extraNames() {
+		args = append(args, "-d", a)
+	}
+	args = append(args, "--non-interactive", "--agree-tos", "-m", fmt.Sprintf("admin@%s", d.Domain))
+	certbotMu.Lock()
+	errC := d.run("certbot", args...)
+	certbotMu.Unlock()
+	d.audit("cert_issue", filepath.Join("/etc/letsencrypt/live", d.Domain), strings.Join(append([]string{d.Domain}, d.extraNames()...), " "), errC)
+	if errC != nil {
+		d.logf("ERROR", "Ошибка SSL!")
+		return newDeployError(ErrCertbot, "certbot: %v", errC)
+	}
+	d.logf("INFO", "SSL выпущен => убираем затычку, ставим финальный SSL, CF=full")
+	return nil
+}
+
+// (K) Выбор финального шаблона и замена затычки на него
+func stepFinalConfig(d *deployment) error {
+	if d.SSLNeeded != "yes" {
+		d.logf("INFO", "SSL не нужен => убираем затычку, ставим final_template, CF=flexible")
+	}
+	finalTemplate := finalTemplateFor(d)
+	os.Remove(filepath.Join(NGINX_ENABLED, d.Domain))
+	os.Remove(filepath.Join(NGINX_AVAILABLE, d.Domain))
+	newConf := filepath.Join(NGINX_AVAILABLE, d.Domain)
+	confText, errF := renderTemplate(d, finalTemplate)
+	if errF != nil {
+		return newDeployError(ErrTemplate, "шаблон %s: %v", finalTemplate, errF)
+	}
+	errW := os.WriteFile(newConf, []byte(confText), 0644)
+	d.audit("nginx_write", newConf, "шаблон "+finalTemplate, errW)
+	if err := errW; err != nil {
+		return newDeployError(ErrTemplate, "запись %s: %v", newConf, err)
+	}
+	os.Symlink(newConf, filepath.Join(NGINX_ENABLED, d.Domain))
+	if err := reloadNginx(); err != nil {
+		return newDeployError(ErrTemplate, "финальный конфиг %s: %v", d.Domain, err)
+	}
+	if d.SSLNeeded == "yes" {
+		// Удаляем временные самоподписанные сертификаты, так как теперь используется валидный сертификат
+		selfSignedDir := "/etc/nginx/self-signed"
+		os.Remove(filepath.Join(selfSignedDir, d.Domain+".crt"))
+		os.Remove(filepath.Join(selfSignedDir, d.Domain+".key"))
+		d.audit("cert_delete", filepath.Join(selfSignedDir, d.Domain+".crt"), "самоподписанный сертификат затычки", nil)
+		d.logf("INFO", "Удалены временные самоподписанные сертификаты для %s", d.Domain)
+	}
+	return nil
+}
+
+// finalTemplateFor выбирает шаблон nginx по SSL и www.
+func finalTemplateFor(d *deployment) string {
+	if d.SSLNeeded == "no" && d.UseWww == "no" {
+		return TPL_NOSSL_NOWWW
+	} else if d.SSLNeeded == "no" && d.UseWww == "yes" {
+		return TPL_NOSSL_WWW
+	} else if d.SSLNeeded == "yes" && d.UseWww == "no" {
+		return TPL_SSL_NOWWW
+	}
+	return TPL_SSL_WWW
+}
+
+// (M) CloudFlare SSL: full, если выпущен сертификат, иначе flexible
+func stepCFSSLFinal(d *deployment) error {
+	mode := "flexible"
+	if d.SSLNeeded == "yes" {
+		mode = "full"
+	}
+	if useCloudflare && d.skipCFZoneSettings("SSL "+mode) {
+		return nil
+	}
+	if useCloudflare {
+		err := setCFSSLMode(d.cf(), mode)
+		d.audit("cf_patch", "zones/"+d.CFZoneID+"/settings/ssl", "value="+mode, err)
+	} else {
+		d.logf("INFO", "Пропускаем установку CloudFlare SSL (%s) для %s.", mode, d.Domain)
+	}
+	return nil
+}
+
+// (N) Применяем дефолтные настройки CloudFlare, если используется
+func stepCFDefaults(d *deployment) error {
+	if useCloudflare && d.skipCFZoneSettings("дефолтные настройки CF") {
+		return nil
+	}
+	if useCloudflare {
+		d.logf("INFO", "Применяем финальные дефолтные настройки CF...")
+		applyDefaultCFSettings(d.cf(), func(st cfSetting, err error) {
+			d.audit("cf_patch", "zones/"+d.CFZoneID+"/settings/"+st.Key, "value="+st.Value, err)
+		})
+	} else {
+		d.logf("INFO", "CloudFlare не настроен, пропускаем применение настроек CF.")
+	}
+	return nil
+}
@@ -0,0 +1,385 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// ------------------------------
+// (23) Корзина: копия сайта перед удалением и `autodeploy restore`
+// ------------------------------
+
+// siteBundle — полная копия сайта: bundle.json и рядом site.tar.gz (файлы
+// сайта), system.tar.gz (конфиг nginx, сертификаты; пути от /), journal.json
+// (журнал деплоя без паролей и ключа API Cloudflare), db.sql и users.sql
+// (пользователь MySQL с хешем пароля и его права), credentials.txt (строка
+// сайта из WP_LOG). В копиях, сделанных до появления journal.json, журнал
+// лежит в system.tar.gz. В таком виде хранятся копии в корзине
+// (TRASH_DIR/<домен>/<метка>/) и резервные копии (раздел (24)).
+type siteBundle struct {
+	Domain      string    `json:"domain"`
+	Folder      string    `json:"folder"` // имя папки сайта в WATCH_DIR на момент копии
+	Created     time.Time `json:"created"`
+	Expires     time.Time `json:"expires"` // только для корзины
+	Files       []string  `json:"files"`   // что лежит в system.tar.gz
+	Journal     bool      `json:"journal"` // есть journal.json
+	Database    bool      `json:"database"`
+	User        bool      `json:"user"`
+	DBName      string    `json:"db_name,omitempty"` // из реестра сайтов; в старых копиях пусто — имя домена
+	DBUser      string    `json:"db_user,omitempty"`
+	Credentials bool      `json:"credentials"`
+	Trigger     string    `json:"trigger"`
+
+	dir string
+}
+
+// trashSite сохраняет всё, что удалит removeSite, в новую папку корзины и
+// возвращает её путь. Если что-то сохранить не удалось, папка удаляется
+// целиком: неполная копия хуже, чем отказ от удаления.
+func (d *deployment) trashSite() (string, error) {
+	b := siteBundle{Domain: d.Domain, Folder: d.Folder, Created: time.Now(), Trigger: d.Trigger}
+	b.Expires = b.Created.AddDate(0, 0, TRASH_GRACE_DAYS)
+	dir := filepath.Join(TRASH_DIR, d.Domain, b.Created.Format("20060102-150405"))
+	err := b.save(d, dir)
+	d.audit("trash_create", dir, "копия перед удалением сайта", err)
+	if err != nil {
+		os.RemoveAll(dir)
+		return "", err
+	}
+	return dir, nil
+}
+
+func (b *siteBundle) save(d *deployment, dir string) error {
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return err
+	}
+	if err := d.run("tar", "-czpf", filepath.Join(dir, "site.tar.gz"), "-C", filepath.Join(WATCH_DIR, b.Folder), "."); err != nil {
+		return fmt.Errorf("архив файлов сайта: %v", err)
+	}
+	for _, path := range removalPaths(b.Domain, b.Folder)[1:] {
+		if path == journalPath(b.Domain) {
+			continue // журнал — отдельно, без секретов
+		}
+		if _, err := os.Lstat(path); err == nil {
+			b.Files = append(b.Files, strings.TrimPrefix(path, "/"))
+		}
+	}
+	if len(b.Files) > 0 {
+		args := append([]string{"-czpf", filepath.Join(dir, "system.tar.gz"), "-C", "/"}, b.Files...)
+		if err := d.run("tar", args...); err != nil {
+			return fmt.Errorf("архив конфигов и сертификатов: %v", err)
+		}
+	}
+	// Журнал хранит пароли сайта и ключ API Cloudflare, а копия может уйти в
+	// S3. Для восстановления они не нужны: деплой уже завершён.
+	if j, err := loadDeployment(b.Domain); err == nil {
+		j.DBPass, j.AdminPass, j.CFAPIKey = "", "", ""
+		data, _ := json.MarshalIndent(j, "", "  ")
+		if err := os.WriteFile(filepath.Join(dir, "journal.json"), data, 0600); err != nil {
+			return fmt.Errorf("журнал деплоя: %v", err)
+		}
+		b.Journal = true
+	}
+	var err error
+	if b.DBName, b.DBUser, err = siteDatabase(b.Domain); err != nil {
+		return fmt.Errorf("реестр сайтов: %v", err)
+	}
+	if b.Database, err = mysqlDatabaseExists(b.DBName); err != nil {
+		return fmt.Errorf("база: %v", err)
+	}
+	if b.Database {
+		if err := d.run("mysqldump", "-u", "root", "--single-transaction", "--routines", "--triggers",
+			"--result-file="+filepath.Join(dir, "db.sql"), "--databases", b.DBName); err != nil {
+			return fmt.Errorf("mysqldump: %v", err)
+		}
+	}
+	if b.User, err = mysqlUserExists(b.DBUser); err != nil {
+		return fmt.Errorf("пользователь MySQL: %v", err)
+	}
+	if b.User {
+		// По одному запросу в строке: restore выполняет их по очереди
+		create, err := mysqlColumn("SHOW CREATE USER ?@'localhost'", b.DBUser)
+		if err != nil {
+			return fmt.Errorf("пользователь MySQL: %v", err)
+		}
+		grants, err := mysqlColumn("SHOW GRANTS FOR ?@'localhost'", b.DBUser)
+		if err != nil {
+			return fmt.Errorf("пользователь MySQL: %v", err)
+		}
+		var sql strings.Builder
+		for _, q := range append(create, grants...) {
+			sql.WriteString(strings.Join(strings.Fields(q), " ") + ";\n")
+		}
+		if err := os.WriteFile(filepath.Join(dir, "users.sql"), []byte(sql.String()), 0600); err != nil {
+			return err
+		}
+	}
+	if lines := credentialLines(b.Domain); lines != "" {
+		if err := os.WriteFile(filepath.Join(dir, "credentials.txt"), []byte(lines), 0600); err != nil {
+			return err
+		}
+		b.Credentials = true
+	}
+	data, _ := json.MarshalIndent(b, "", "  ")
+	return writeFileAtomic(filepath.Join(dir, "bundle.json"), data, 0600)
+}
+
+// credentialLines — строки домена из WP_LOG (реквизиты WordPress).
+func credentialLines(domain string) string {
+	data, _ := os.ReadFile(WP_LOG)
+	var out strings.Builder
+	for _, line := range strings.SplitAfter(string(data), "\n") {
+		if strings.HasPrefix(line, domain+"|") {
+			out.WriteString(line)
+		}
+	}
+	return out.String()
+}
+
+// listTrash возвращает копии домена (или всех доменов, если domain пуст),
+// от старых к новым.
+func listTrash(domain string) []*siteBundle {
+	pattern := filepath.Join(TRASH_DIR, "*", "*", "bundle.json")
+	if domain != "" {
+		pattern = filepath.Join(TRASH_DIR, domain, "*", "bundle.json")
+	}
+	paths, _ := filepath.Glob(pattern)
+	var list []*siteBundle
+	for _, path := range paths {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			continue
+		}
+		b := &siteBundle{dir: filepath.Dir(path)}
+		if err := json.Unmarshal(data, b); err != nil {
+			log.Printf("[WARN] Корзина: %s повреждён: %v", path, err)
+			continue
+		}
+		list = append(list, b)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Created.Before(list[j].Created) })
+	return list
+}
+
+// trashRetention удаляет копии, у которых истёк срок хранения.
+func trashRetention() {
+	for {
+		for _, b := range listTrash("") {
+			if time.Now().Before(b.Expires) {
+				continue
+			}
+			err := os.RemoveAll(b.dir)
+			auditRecord(auditEntry{Action: "trash_purge", Target: b.dir, Domain: b.Domain,
+				Details: "истёк срок хранения копии", Trigger: "timer:trash_grace_days"}, err)
+			if err != nil {
+				log.Printf("[WARN] Корзина: не смогли удалить %s: %v", b.dir, err)
+				continue
+			}
+			log.Printf("[INFO] Корзина: копия %s от %s удалена (срок хранения истёк)", b.Domain, b.Created.Format("02.01.2006 15:04"))
+			os.Remove(filepath.Dir(b.dir))
+		}
+		time.Sleep(time.Hour)
+	}
+}
+
+// cmdRestore — `autodeploy restore <домен> [метка]`: возвращает сайт из
+// корзины (по умолчанию — последнюю копию). Без аргументов печатает содержимое
+// корзины. Сайт восстанавливается в папку <домен>, как после успешного деплоя.
+func cmdRestore(args []string) int {
+	if len(args) == 0 {
+		list := listTrash("")
+		if len(list) == 0 {
+			fmt.Println("Корзина пуста.")
+		}
+		for _, b := range list {
+			fmt.Printf("%-30s %s  удалён %s, хранится до %s\n", b.Domain, filepath.Base(b.dir),
+				b.Created.Format("02.01.2006 15:04"), b.Expires.Format("02.01.2006 15:04"))
+		}
+		return 0
+	}
+	if len(args) > 2 {
+		fmt.Fprintln(os.Stderr, "Использование: autodeploy restore [<домен> [метка]]")
+		return 2
+	}
+	domain, ok := domainArg(args[0])
+	if !ok {
+		return 2
+	}
+	list := listTrash(domain)
+	if len(list) == 0 {
+		fmt.Fprintf(os.Stderr, "[ERROR] В корзине нет копий %s\n", domain)
+		return 1
+	}
+	b := list[len(list)-1]
+	if len(args) == 2 {
+		b = nil
+		for _, c := range list {
+			if filepath.Base(c.dir) == args[1] {
+				b = c
+			}
+		}
+		if b == nil {
+			fmt.Fprintf(os.Stderr, "[ERROR] Копии %s с меткой %s нет\n", domain, args[1])
+			return 1
+		}
+	}
+	if err := b.restoreSite("cli:restore"); err != nil {
+		log.Printf("[ERROR] Сайт %s не восстановлен: %v", domain, err)
+		return 1
+	}
+	os.RemoveAll(b.dir)
+	os.Remove(filepath.Dir(b.dir))
+	log.Printf("[INFO] Сайт %s восстановлен из корзины (копия от %s).", domain, b.Created.Format("02.01.2006 15:04"))
+	return 0
+}
+
+// restoreSite восстанавливает сайт из копии b в папку <домен>, как после
+// успешного деплоя. Если что-то не удалось, копия остаётся на месте.
+func (b *siteBundle) restoreSite(trigger string) error {
+	unlock, err := lockDomain(b.Domain)
+	if err != nil {
+		return fmt.Errorf("блокировка домена: %v", err)
+	}
+	defer unlock()
+	d := &deployment{Domain: b.Domain, Folder: b.Domain, Step: "restore", Trigger: trigger}
+	if err := b.checkFree(); err != nil {
+		return err
+	}
+	if err := b.restore(d); err != nil {
+		return fmt.Errorf("восстановление из %s прервано: %v", b.dir, err)
+	}
+	// Журнал деплоя восстановлен из копии: берём из него тип сайта
+	if j, err := loadDeployment(b.Domain); err == nil {
+		d.SiteType = j.SiteType
+	}
+	if b.Database || b.User {
+		d.DBName, d.DBUser = b.databaseNames()
+	}
+	if err := recordSite(d); err != nil {
+		d.logf("WARN", "Не смогли записать %s в реестр сайтов %s: %v", b.Domain, SITES_FILE, err)
+	}
+	if err := reloadNginx(); err != nil {
+		d.logf("WARN", "После восстановления nginx не перезагружен: %v", err)
+	}
+	return nil
+}
+
+// databaseNames — база и пользователь MySQL сайта в копии b.
+func (b *siteBundle) databaseNames() (db, user string) {
+	if b.DBName == "" {
+		return b.Domain, b.Domain
+	}
+	return b.DBName, b.DBUser
+}
+
+// checkFree проверяет, что домен не занят заново после удаления: восстановление
+// не должно ничего перезаписывать.
+func (b *siteBundle) checkFree() error {
+	folders, _ := filepath.Glob(filepath.Join(WATCH_DIR, b.Domain+"*"))
+	for _, f := range folders {
+		name := filepath.Base(f)
+		if realdom, _, ok := parseFolderName(name); name == b.Domain || (ok && realdom == b.Domain) {
+			return fmt.Errorf("папка %s уже существует", f)
+		}
+	}
+	if _, err := os.Lstat(filepath.Join(NGINX_AVAILABLE, b.Domain)); err == nil {
+		return fmt.Errorf("конфиг nginx %s уже существует", filepath.Join(NGINX_AVAILABLE, b.Domain))
+	}
+	dbName, dbUser := b.databaseNames()
+	if b.Database || b.User {
+		sites, err := loadSites()
+		if err != nil {
+			return err
+		}
+		for _, r := range sites {
+			if r.Domain != b.Domain && (r.DBName == dbName || r.DBUser == dbUser) {
+				return fmt.Errorf("база %s в реестре сайтов записана за %s", dbName, r.Domain)
+			}
+		}
+	}
+	if b.Database {
+		if ok, err := mysqlDatabaseExists(dbName); err != nil {
+			return err
+		} else if ok {
+			return fmt.Errorf("база %s уже существует", dbName)
+		}
+	}
+	if b.User {
+		if ok, err := mysqlUserExists(dbUser); err != nil {
+			return err
+		} else if ok {
+			return fmt.Errorf("пользователь MySQL '%s'@'localhost' уже существует", dbUser)
+		}
+	}
+	return nil
+}
+
+func (b *siteBundle) restore(d *deployment) error {
+	if len(b.Files) > 0 {
+		err := d.run("tar", "-xzpf", filepath.Join(b.dir, "system.tar.gz"), "-C", "/")
+		d.audit("trash_restore", "/", "конфиг nginx, сертификаты: "+strings.Join(b.Files, " "), err)
+		if err != nil {
+			return err
+		}
+	}
+	if b.Journal {
+		data, err := os.ReadFile(filepath.Join(b.dir, "journal.json"))
+		if err == nil {
+			err = os.MkdirAll(JOURNAL_DIR, 0700)
+		}
+		if err == nil {
+			err = writeFileAtomic(journalPath(b.Domain), data, 0600)
+		}
+		d.audit("trash_restore", journalPath(b.Domain), "журнал деплоя", err)
+		if err != nil {
+			return fmt.Errorf("журнал деплоя: %v", err)
+		}
+	}
+	dbName, dbUser := b.databaseNames()
+	if b.Database {
+		// Дамп сделан с --databases: он сам создаёт базу
+		err := d.run("mysql", "-u", "root", "-e", "source "+filepath.Join(b.dir, "db.sql"))
+		d.audit("db_restore", dbName, "из корзины", err)
+		if err != nil {
+			return fmt.Errorf("база: %v", err)
+		}
+	}
+	if b.User {
+		data, err := os.ReadFile(filepath.Join(b.dir, "users.sql"))
+		if err == nil {
+			err = mysqlExec(strings.Split(strings.TrimSuffix(strings.TrimSpace(string(data)), ";"), ";\n")...)
+		}
+		d.audit("db_user_restore", dbUser+"@localhost", "из корзины", err)
+		if err != nil {
+			return fmt.Errorf("пользователь MySQL: %v", err)
+		}
+	}
+	if b.Credentials {
+		// Реквизиты дописываются, только если их в WP_LOG нет (удаление
+		// сайта их не стирает)
+		data, err := os.ReadFile(filepath.Join(b.dir, "credentials.txt"))
+		if err == nil && credentialLines(b.Domain) == "" {
+			var f *os.File
+			if f, err = os.OpenFile(WP_LOG, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644); err == nil {
+				_, err = f.Write(data)
+				f.Close()
+			}
+		}
+		if err != nil {
+			return fmt.Errorf("реквизиты в %s: %v", WP_LOG, err)
+		}
+	}
+	webroot := filepath.Join(WATCH_DIR, d.Folder)
+	err := os.MkdirAll(webroot, 0755)
+	if err == nil {
+		err = d.run("tar", "-xzpf", filepath.Join(b.dir, "site.tar.gz"), "-C", webroot)
+	}
+	d.audit("files_restore", webroot, "из корзины", err)
+	return err
+}
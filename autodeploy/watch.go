@@ -0,0 +1,178 @@
+package main
+
+import (
+	"encoding/binary"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// ------------------------------
+// (5) Встроенный inotify-наблюдатель (вместо inotifywait)
+// ------------------------------
+
+// fsEventKind — тип события в наблюдаемом каталоге.
+type fsEventKind int
+
+const (
+	fsCreate fsEventKind = iota
+	fsMovedTo
+	fsDelete
+	fsCloseWrite
+)
+
+func (k fsEventKind) String() string {
+	switch k {
+	case fsCreate:
+		return "create"
+	case fsMovedTo:
+		return "moved_to"
+	case fsDelete:
+		return "delete"
+	case fsCloseWrite:
+		return "close_write"
+	}
+	return "unknown"
+}
+
+// fsEvent — одно событие inotify. Name — имя файла/папки внутри каталога
+// как есть (с пробелами и любыми символами), без пути.
+type fsEvent struct {
+	Kind  fsEventKind
+	Name  string
+	IsDir bool
+}
+
+const watchMask = syscall.IN_CREATE | syscall.IN_MOVED_TO | syscall.IN_DELETE | syscall.IN_CLOSE_WRITE |
+	syscall.IN_DELETE_SELF | syscall.IN_MOVE_SELF
+
+// watchDir следит за dir и отправляет события в out. Если наблюдение
+// ломается (ошибка чтения, переполнение очереди, каталог удалён или
+// перемещён), оно пересоздаётся через 5 сек. Функция не возвращается.
+func watchDir(dir string, out chan<- fsEvent) {
+	for {
+		err := watchDirOnce(dir, out)
+		log.Printf("[WARN] Наблюдение за %s прервано: %v. Перезапуск через 5 сек...", dir, err)
+		time.Sleep(5 * time.Second)
+	}
+}
+
+// watchDirOnce — один цикл наблюдения; возвращает причину, по которой он прервался.
+func watchDirOnce(dir string, out chan<- fsEvent) error {
+	fd, err := syscall.InotifyInit1(syscall.IN_CLOEXEC)
+	if err != nil {
+		return fmt.Errorf("inotify_init1: %v", err)
+	}
+	defer syscall.Close(fd)
+	if _, err := syscall.InotifyAddWatch(fd, dir, watchMask); err != nil {
+		return fmt.Errorf("inotify_add_watch(%s): %v", dir, err)
+	}
+	log.Printf("[INFO] Наблюдаем за %s (inotify)", dir)
+	// События, случившиеся до этого момента, inotify уже не покажет
+	reconcileWatchDir(dir, out)
+
+	buf := make([]byte, 64*(syscall.SizeofInotifyEvent+syscall.NAME_MAX+1))
+	for {
+		n, err := syscall.Read(fd, buf)
+		if err == syscall.EINTR {
+			continue
+		}
+		if err != nil {
+			return fmt.Errorf("read: %v", err)
+		}
+		if n < syscall.SizeofInotifyEvent {
+			return fmt.Errorf("короткое чтение из inotify (%d байт)", n)
+		}
+		for off := 0; off+syscall.SizeofInotifyEvent <= n; {
+			mask := binary.NativeEndian.Uint32(buf[off+4:])
+			nameLen := int(binary.NativeEndian.Uint32(buf[off+12:]))
+			nameStart := off + syscall.SizeofInotifyEvent
+			if nameStart+nameLen > n {
+				return fmt.Errorf("обрезанное событие inotify")
+			}
+			name := strings.TrimRight(string(buf[nameStart:nameStart+nameLen]), "\x00")
+			off = nameStart + nameLen
+
+			switch {
+			case mask&syscall.IN_Q_OVERFLOW != 0:
+				return fmt.Errorf("переполнение очереди событий inotify")
+			case mask&(syscall.IN_DELETE_SELF|syscall.IN_MOVE_SELF|syscall.IN_IGNORED) != 0:
+				return fmt.Errorf("каталог %s удалён или перемещён", dir)
+			}
+			ev := fsEvent{Name: name, IsDir: mask&syscall.IN_ISDIR != 0}
+			switch {
+			case mask&syscall.IN_CREATE != 0:
+				ev.Kind = fsCreate
+			case mask&syscall.IN_MOVED_TO != 0:
+				ev.Kind = fsMovedTo
+			case mask&syscall.IN_DELETE != 0:
+				ev.Kind = fsDelete
+			case mask&syscall.IN_CLOSE_WRITE != 0:
+				ev.Kind = fsCloseWrite
+			default:
+				continue
+			}
+			out <- ev
+		}
+	}
+}
+
+// ------------------------------
+// (6) Разбор имени папки по протоколу суффиксов
+// ------------------------------
+
+// parseFolderName разбирает имя папки: "<домен>_777" — удаление сайта,
+// "<домен>_N" (N=0..7) — деплой со статусом N, "<домен>_m" — деплой по
+// манифесту autodeploy.yaml. ok=false, если суффикса нет.
+func parseFolderName(folderName string) (realdom, idx string, ok bool) {
+	if strings.HasSuffix(folderName, "_777") {
+		return strings.TrimSuffix(folderName, "_777"), "777", true
+	}
+	parts := strings.Split(folderName, "_")
+	if len(parts) < 2 {
+		return "", "", false
+	}
+	idx = parts[len(parts)-1]
+	if !strings.ContainsAny(idx, "01234567m") || len(idx) != 1 {
+		return "", "", false
+	}
+	return strings.Join(parts[:len(parts)-1], "_"), idx, true
+}
+
+// reconcileWatchDir просматривает dir и ставит в очередь (как событие create)
+// каждую папку с суффиксом статуса — например, созданную, пока демон был
+// остановлен или пока наблюдение перезапускалось.
+func reconcileWatchDir(dir string, out chan<- fsEvent) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		log.Printf("[WARN] Сверка %s: не смогли прочитать каталог: %v", dir, err)
+		return
+	}
+	var deploy, remove []string
+	for _, e := range entries {
+		if !e.IsDir() {
+			continue
+		}
+		realdom, idx, ok := parseFolderName(e.Name())
+		if !ok || realdom == "" {
+			continue
+		}
+		if idx == "777" {
+			remove = append(remove, e.Name())
+		} else {
+			deploy = append(deploy, e.Name())
+		}
+	}
+	if len(deploy)+len(remove) == 0 {
+		log.Printf("[INFO] Сверка %s: необработанных папок нет.", dir)
+		return
+	}
+	log.Printf("[INFO] Сверка %s: в очередь на деплой %d %v, на удаление %d %v",
+		dir, len(deploy), deploy, len(remove), remove)
+	for _, name := range append(deploy, remove...) {
+		out <- fsEvent{Kind: fsCreate, Name: name, IsDir: true}
+	}
+}
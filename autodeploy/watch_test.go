@@ -0,0 +1,35 @@
+package main
+
+import "testing"
+
+// ------------------------------
+// Имя папки (раздел (6))
+// ------------------------------
+
+func TestParseFolderName(t *testing.T) {
+	tests := []struct {
+		in      string
+		realdom string
+		idx     string
+		ok      bool
+	}{
+		{"example.com_0", "example.com", "0", true},
+		{"example.com_7", "example.com", "7", true},
+		{"example.com_m", "example.com", "m", true},
+		{"example.com_777", "example.com", "777", true},
+		{"my_site.com_m", "my_site.com", "m", true},
+		{"example.com", "", "", false},
+		{"example.com_8", "", "", false},
+		{"example.com_M", "", "", false},
+		{"example.com_mm", "", "", false},
+		{"example.com_", "", "", false},
+		{"example.com_77", "", "", false},
+	}
+	for _, tt := range tests {
+		realdom, idx, ok := parseFolderName(tt.in)
+		if realdom != tt.realdom || idx != tt.idx || ok != tt.ok {
+			t.Errorf("parseFolderName(%q) = %q, %q, %v; ожидалось %q, %q, %v",
+				tt.in, realdom, idx, ok, tt.realdom, tt.idx, tt.ok)
+		}
+	}
+}
@@ -0,0 +1,299 @@
+package main
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// ------------------------------
+// (20) Вебхуки о событиях деплоя
+// ------------------------------
+
+// События, о которых сообщают вебхуки.
+const (
+	EV_DEPLOY_STARTED   = "deploy.started"
+	EV_STEP_FINISHED    = "deploy.step_finished"
+	EV_DEPLOY_SUCCEEDED = "deploy.succeeded"
+	EV_DEPLOY_FAILED    = "deploy.failed"
+	EV_SITE_REMOVED     = "site.removed"
+)
+
+var webhookEvents = []string{EV_DEPLOY_STARTED, EV_STEP_FINISHED, EV_DEPLOY_SUCCEEDED, EV_DEPLOY_FAILED, EV_SITE_REMOVED}
+
+// webhookEndpoint — адрес из конфига. Пустой Events — все события. Если
+// задан Secret, тело подписывается: X-Autodeploy-Signature: sha256=<hmac>.
+type webhookEndpoint struct {
+	URL    string   `json:"url"`
+	Events []string `json:"events"`
+	Secret string   `json:"secret"`
+}
+
+func (w webhookEndpoint) validate() error {
+	u, err := url.Parse(w.URL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("url: %q — нужен адрес http(s)://", w.URL)
+	}
+	for _, ev := range w.Events {
+		known := false
+		for _, k := range webhookEvents {
+			known = known || ev == k
+		}
+		if !known {
+			return fmt.Errorf("events: %q, допустимо %v", ev, webhookEvents)
+		}
+	}
+	return nil
+}
+
+func (w webhookEndpoint) wants(event string) bool {
+	if len(w.Events) == 0 {
+		return true
+	}
+	for _, ev := range w.Events {
+		if ev == event {
+			return true
+		}
+	}
+	return false
+}
+
+// webhookEvent — тело запроса вебхука.
+type webhookEvent struct {
+	ID         string     `json:"id"`
+	Event      string     `json:"event"`
+	Time       time.Time  `json:"time"`
+	Server     string     `json:"server"`
+	Domain     string     `json:"domain"`
+	Folder     string     `json:"folder,omitempty"`
+	SiteType   string     `json:"site_type,omitempty"`
+	SSL        string     `json:"ssl,omitempty"`
+	WWW        string     `json:"www,omitempty"`
+	Step       string     `json:"step,omitempty"`
+	StepMs     int64      `json:"step_duration_ms,omitempty"`
+	Started    *time.Time `json:"started,omitempty"`
+	Finished   *time.Time `json:"finished,omitempty"`
+	DurationMs int64      `json:"duration_ms,omitempty"`
+	ErrorCode  string     `json:"error_code,omitempty"`
+	ErrorName  string     `json:"error_name,omitempty"`
+	FailedStep string     `json:"failed_step,omitempty"`
+	Error      string     `json:"error,omitempty"`
+}
+
+// event собирает событие о деплое d.
+func (d *deployment) event(kind string) *webhookEvent {
+	ev := &webhookEvent{
+		Event:    kind,
+		Time:     time.Now(),
+		Server:   SERVER_IP,
+		Domain:   d.Domain,
+		Folder:   d.Folder,
+		SiteType: d.SiteType,
+		SSL:      d.SSLNeeded,
+		WWW:      d.UseWww,
+	}
+	if !d.Started.IsZero() {
+		started := d.Started
+		ev.Started = &started
+	}
+	if !d.Finished.IsZero() {
+		finished := d.Finished
+		ev.Finished = &finished
+		if ev.Started != nil {
+			ev.DurationMs = d.Finished.Sub(d.Started).Milliseconds()
+		}
+	}
+	if kind == EV_DEPLOY_FAILED {
+		code := errCodeByCode(d.ErrorCode)
+		ev.ErrorCode, ev.ErrorName = code.Code, code.Name
+		ev.FailedStep, ev.Error = d.FailedStep, d.Error
+	}
+	return ev
+}
+
+// outboxItem — одна доставка события на один адрес. Хранится файлом в
+// OUTBOX_DIR до успешной отправки, поэтому переживает рестарт демона;
+// события из команд (retry и т.п.) отправляет работающий демон.
+type outboxItem struct {
+	URL         string          `json:"url"`
+	Event       string          `json:"event"`
+	Payload     json.RawMessage `json:"payload"`
+	Attempts    int             `json:"attempts"`
+	NextAttempt time.Time       `json:"next_attempt"`
+	LastError   string          `json:"last_error,omitempty"`
+}
+
+// outboxWake будит webhookSender, когда в очереди появилось новое событие.
+var outboxWake = make(chan struct{}, 1)
+
+// emitEvent ставит событие в очередь для всех подписанных адресов.
+func emitEvent(ev *webhookEvent) {
+	var targets []webhookEndpoint
+	for _, w := range WEBHOOKS {
+		if w.wants(ev.Event) {
+			targets = append(targets, w)
+		}
+	}
+	if len(targets) == 0 {
+		return
+	}
+	id := make([]byte, 8)
+	rand.Read(id)
+	ev.ID = hex.EncodeToString(id)
+	payload, err := json.Marshal(ev)
+	if err != nil {
+		log.Printf("[ERROR] Вебхук %s: %v", ev.Event, err)
+		return
+	}
+	if err := os.MkdirAll(OUTBOX_DIR, 0700); err != nil {
+		log.Printf("[ERROR] Не смогли создать %s: %v", OUTBOX_DIR, err)
+		return
+	}
+	for i, w := range targets {
+		item := outboxItem{URL: w.URL, Event: ev.Event, Payload: payload, NextAttempt: ev.Time}
+		// Имя файла задаёт порядок доставки
+		name := fmt.Sprintf("%020d-%s-%02d.json", ev.Time.UnixNano(), ev.ID, i)
+		if err := item.save(filepath.Join(OUTBOX_DIR, name)); err != nil {
+			log.Printf("[ERROR] Не смогли поставить вебхук %s в очередь: %v", ev.Event, err)
+		}
+	}
+	select {
+	case outboxWake <- struct{}{}:
+	default:
+	}
+}
+
+func (it *outboxItem) save(path string) error {
+	data, err := json.MarshalIndent(it, "", "  ")
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(path, data, 0600)
+}
+
+// webhookSender — фоновая доставка очереди вебхуков.
+func webhookSender() {
+	for {
+		deliverOutbox()
+		select {
+		case <-outboxWake:
+		case <-time.After(10 * time.Second):
+		}
+	}
+}
+
+// deliverOutbox отправляет все события, время которых пришло. События на один
+// адрес уходят строго по порядку: пока не доставлено раннее, позднее ждёт.
+func deliverOutbox() {
+	entries, err := os.ReadDir(OUTBOX_DIR)
+	if err != nil {
+		return
+	}
+	blocked := map[string]bool{}
+	for _, e := range entries {
+		if e.IsDir() || !strings.HasSuffix(e.Name(), ".json") {
+			continue
+		}
+		path := filepath.Join(OUTBOX_DIR, e.Name())
+		data, err := os.ReadFile(path)
+		if err != nil {
+			continue
+		}
+		var it outboxItem
+		if err := json.Unmarshal(data, &it); err != nil {
+			log.Printf("[WARN] Битый файл очереди вебхуков %s: %v", path, err)
+			moveToDead(path)
+			continue
+		}
+		if blocked[it.URL] || time.Now().Before(it.NextAttempt) {
+			blocked[it.URL] = true
+			continue
+		}
+		endpoint, ok := webhookByURL(it.URL)
+		if !ok {
+			log.Printf("[WARN] Вебхука %s больше нет в конфиге, событие %s удалено из очереди", it.URL, it.Event)
+			os.Remove(path)
+			continue
+		}
+		err = postWebhook(endpoint, it.Event, e.Name(), it.Payload)
+		if err == nil {
+			os.Remove(path)
+			continue
+		}
+		it.Attempts++
+		it.LastError = err.Error()
+		blocked[it.URL] = true
+		if it.Attempts >= WEBHOOK_ATTEMPTS {
+			log.Printf("[ERROR] Вебхук %s (%s) не доставлен за %d попыток: %v", it.URL, it.Event, it.Attempts, err)
+			it.save(path)
+			moveToDead(path)
+			continue
+		}
+		// 10 с, 20 с, 40 с ... но не реже раза в час
+		backoff := 10 * time.Second << uint(it.Attempts-1)
+		if backoff > time.Hour || backoff <= 0 {
+			backoff = time.Hour
+		}
+		it.NextAttempt = time.Now().Add(backoff)
+		log.Printf("[WARN] Вебхук %s (%s), попытка %d: %v; следующая через %s", it.URL, it.Event, it.Attempts, err, backoff)
+		if err := it.save(path); err != nil {
+			log.Printf("[ERROR] Не смогли обновить очередь вебхуков %s: %v", path, err)
+		}
+	}
+}
+
+func webhookByURL(u string) (webhookEndpoint, bool) {
+	for _, w := range WEBHOOKS {
+		if w.URL == u {
+			return w, true
+		}
+	}
+	return webhookEndpoint{}, false
+}
+
+// moveToDead убирает недоставляемое событие в OUTBOX_DIR/dead (для разбора вручную).
+func moveToDead(path string) {
+	dead := filepath.Join(OUTBOX_DIR, "dead")
+	os.MkdirAll(dead, 0700)
+	os.Rename(path, filepath.Join(dead, filepath.Base(path)))
+}
+
+// postWebhook отправляет одно событие. Успех — любой ответ 2xx.
+func postWebhook(w webhookEndpoint, event, delivery string, payload []byte) error {
+	req, err := http.NewRequest("POST", w.URL, bytes.NewReader(payload))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "autodeploy")
+	req.Header.Set("X-Autodeploy-Event", event)
+	req.Header.Set("X-Autodeploy-Delivery", strings.TrimSuffix(delivery, ".json"))
+	if w.Secret != "" {
+		mac := hmac.New(sha256.New, []byte(w.Secret))
+		mac.Write(payload)
+		req.Header.Set("X-Autodeploy-Signature", "sha256="+hex.EncodeToString(mac.Sum(nil)))
+	}
+	client := &http.Client{Timeout: time.Duration(WEBHOOK_TIMEOUT) * time.Second}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("ответ %s", resp.Status)
+	}
+	return nil
+}
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+	"syscall"
+)
+
+// ------------------------------
+// (7) Параллельный деплой: воркеры и блокировки
+// ------------------------------
+
+// nginxMu сериализует всё, что проверяет и перезагружает общий nginx
+// (nginx -t, systemctl reload nginx).
+var nginxMu sync.Mutex
+
+// certbotMu сериализует запуски certbot: второй certbot, запущенный
+// одновременно с первым, не ждёт, а падает с «Another instance of Certbot is
+// already running». Выпуск сертификата может идти минуту, поэтому это
+// отдельная блокировка, а не nginxMu.
+var certbotMu sync.Mutex
+
+// reloadNginx проверяет конфигурацию и перезагружает nginx под nginxMu.
+// Если nginx -t не прошёл, nginx не перезагружается, а в ошибке — вывод nginx -t.
+func reloadNginx() error {
+	nginxMu.Lock()
+	defer nginxMu.Unlock()
+	out, err := exec.Command("nginx", "-t").CombinedOutput()
+	os.Stderr.Write(out)
+	if err != nil {
+		return fmt.Errorf("nginx -t: %v\n%s", err, strings.TrimSpace(string(out)))
+	}
+	return runCmd("systemctl", "reload", "nginx")
+}
+
+// lockDomain берёт эксклюзивную блокировку домена (flock на файл в JOURNAL_DIR)
+// и возвращает функцию её снятия. flock конфликтует и между горутинами демона,
+// и между демоном и командами вроде `autodeploy retry`.
+func lockDomain(domain string) (func(), error) {
+	if err := os.MkdirAll(JOURNAL_DIR, 0700); err != nil {
+		return nil, err
+	}
+	f, err := os.OpenFile(filepath.Join(JOURNAL_DIR, domain+".lock"), os.O_CREATE|os.O_RDWR, 0600)
+	if err != nil {
+		return nil, err
+	}
+	if err := syscall.Flock(int(f.Fd()), syscall.LOCK_EX); err != nil {
+		f.Close()
+		return nil, err
+	}
+	return func() {
+		syscall.Flock(int(f.Fd()), syscall.LOCK_UN)
+		f.Close()
+	}, nil
+}
+
+// deployWorker выполняет задания из очереди по одному.
+func deployWorker(jobs <-chan func()) {
+	for job := range jobs {
+		job()
+	}
+}
@@ -0,0 +1,252 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// ------------------------------
+// (8) Мини-парсер YAML (подмножество для манифестов и конфигов)
+// ------------------------------
+
+// Поддерживается: вложенные словари по отступам (только пробелы), списки
+// "- элемент" (скаляры и словари), строчные списки [a, b], строки в кавычках,
+// true/false, null, целые числа и комментарии #. Результат — дерево из
+// map[string]interface{}, []interface{} и скаляров, которое дальше
+// раскладывается в структуру через encoding/json (см. decodeStrict).
+
+type yamlLine struct {
+	num    int
+	indent int
+	text   string
+}
+
+func parseYAML(data []byte) (interface{}, error) {
+	var lines []yamlLine
+	for i, raw := range strings.Split(string(data), "\n") {
+		raw = strings.TrimRight(raw, " \r")
+		text := strings.TrimLeft(raw, " ")
+		if strings.HasPrefix(text, "\t") {
+			return nil, fmt.Errorf("строка %d: табуляция в отступе", i+1)
+		}
+		text = stripYAMLComment(text)
+		if text == "" || text == "---" {
+			continue
+		}
+		lines = append(lines, yamlLine{num: i + 1, indent: len(raw) - len(strings.TrimLeft(raw, " ")), text: text})
+	}
+	if len(lines) == 0 {
+		return map[string]interface{}{}, nil
+	}
+	v, next, err := parseYAMLBlock(lines, 0, lines[0].indent)
+	if err != nil {
+		return nil, err
+	}
+	if next < len(lines) {
+		return nil, fmt.Errorf("строка %d: неожиданный отступ", lines[next].num)
+	}
+	return v, nil
+}
+
+// stripYAMLComment отрезает комментарий " #..." вне кавычек.
+func stripYAMLComment(s string) string {
+	var quote byte
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			}
+		case c == '"' || c == '\'':
+			quote = c
+		case c == '#' && (i == 0 || s[i-1] == ' '):
+			return strings.TrimRight(s[:i], " ")
+		}
+	}
+	return s
+}
+
+func isYAMLListItem(text string) bool {
+	return text == "-" || strings.HasPrefix(text, "- ")
+}
+
+func parseYAMLBlock(lines []yamlLine, i, indent int) (interface{}, int, error) {
+	if isYAMLListItem(lines[i].text) {
+		return parseYAMLList(lines, i, indent)
+	}
+	return parseYAMLMap(lines, i, indent)
+}
+
+func parseYAMLMap(lines []yamlLine, i, indent int) (interface{}, int, error) {
+	m := map[string]interface{}{}
+	for i < len(lines) && lines[i].indent >= indent {
+		l := lines[i]
+		if l.indent > indent {
+			return nil, i, fmt.Errorf("строка %d: неожиданный отступ", l.num)
+		}
+		if isYAMLListItem(l.text) {
+			return nil, i, fmt.Errorf("строка %d: элемент списка вместо ключа", l.num)
+		}
+		key, rest, ok := splitYAMLKey(l.text)
+		if !ok {
+			return nil, i, fmt.Errorf("строка %d: ожидалось \"ключ: значение\"", l.num)
+		}
+		if _, dup := m[key]; dup {
+			return nil, i, fmt.Errorf("строка %d: ключ %q повторяется", l.num, key)
+		}
+		i++
+		if rest != "" {
+			v, err := parseYAMLScalar(rest)
+			if err != nil {
+				return nil, i, fmt.Errorf("строка %d: %v", l.num, err)
+			}
+			m[key] = v
+			continue
+		}
+		switch {
+		case i < len(lines) && lines[i].indent > indent:
+			v, next, err := parseYAMLBlock(lines, i, lines[i].indent)
+			if err != nil {
+				return nil, next, err
+			}
+			m[key], i = v, next
+		case i < len(lines) && lines[i].indent == indent && isYAMLListItem(lines[i].text):
+			// "ключ:\n- a" — список на том же отступе, что и ключ
+			v, next, err := parseYAMLList(lines, i, indent)
+			if err != nil {
+				return nil, next, err
+			}
+			m[key], i = v, next
+		default:
+			m[key] = nil
+		}
+	}
+	return m, i, nil
+}
+
+func parseYAMLList(lines []yamlLine, i, indent int) (interface{}, int, error) {
+	list := []interface{}{}
+	for i < len(lines) && lines[i].indent == indent && isYAMLListItem(lines[i].text) {
+		l := lines[i]
+		item := strings.TrimLeft(strings.TrimPrefix(l.text, "-"), " ")
+		if item == "" {
+			i++
+			if i < len(lines) && lines[i].indent > indent {
+				v, next, err := parseYAMLBlock(lines, i, lines[i].indent)
+				if err != nil {
+					return nil, next, err
+				}
+				list = append(list, v)
+				i = next
+			} else {
+				list = append(list, nil)
+			}
+			continue
+		}
+		if _, _, ok := splitYAMLKey(item); ok {
+			// "- ключ: значение" — словарь, остальные ключи которого идут
+			// с отступом, равным позиции первого ключа
+			sub := append([]yamlLine{}, lines...)
+			sub[i] = yamlLine{num: l.num, indent: l.indent + len(l.text) - len(item), text: item}
+			v, next, err := parseYAMLMap(sub, i, sub[i].indent)
+			if err != nil {
+				return nil, next, err
+			}
+			list = append(list, v)
+			i = next
+			continue
+		}
+		v, err := parseYAMLScalar(item)
+		if err != nil {
+			return nil, i, fmt.Errorf("строка %d: %v", l.num, err)
+		}
+		list = append(list, v)
+		i++
+	}
+	if i < len(lines) && lines[i].indent > indent {
+		return nil, i, fmt.Errorf("строка %d: неожиданный отступ", lines[i].num)
+	}
+	return list, i, nil
+}
+
+// splitYAMLKey делит "ключ: значение" (или "ключ:") на части.
+func splitYAMLKey(text string) (key, rest string, ok bool) {
+	if text[0] == '"' || text[0] == '\'' || text[0] == '[' || text[0] == '{' {
+		return "", "", false
+	}
+	if strings.HasSuffix(text, ":") {
+		return strings.TrimSpace(strings.TrimSuffix(text, ":")), "", true
+	}
+	idx := strings.Index(text, ": ")
+	if idx <= 0 {
+		return "", "", false
+	}
+	return strings.TrimSpace(text[:idx]), strings.TrimSpace(text[idx+2:]), true
+}
+
+func parseYAMLScalar(s string) (interface{}, error) {
+	switch {
+	case s == "[]":
+		return []interface{}{}, nil
+	case s == "{}":
+		return map[string]interface{}{}, nil
+	case strings.HasPrefix(s, "["):
+		if !strings.HasSuffix(s, "]") {
+			return nil, fmt.Errorf("незакрытый список %s", s)
+		}
+		list := []interface{}{}
+		for _, part := range strings.Split(s[1:len(s)-1], ",") {
+			v, err := parseYAMLScalar(strings.TrimSpace(part))
+			if err != nil {
+				return nil, err
+			}
+			list = append(list, v)
+		}
+		return list, nil
+	case strings.HasPrefix(s, `"`):
+		v, err := strconv.Unquote(s)
+		if err != nil {
+			return nil, fmt.Errorf("плохая строка в кавычках %s", s)
+		}
+		return v, nil
+	case strings.HasPrefix(s, "'"):
+		if len(s) < 2 || !strings.HasSuffix(s, "'") {
+			return nil, fmt.Errorf("плохая строка в кавычках %s", s)
+		}
+		return strings.ReplaceAll(s[1:len(s)-1], "''", "'"), nil
+	case s == "true":
+		return true, nil
+	case s == "false":
+		return false, nil
+	case s == "null" || s == "~":
+		return nil, nil
+	}
+	if n, err := strconv.ParseInt(s, 10, 64); err == nil {
+		return n, nil
+	}
+	return s, nil
+}
+
+// decodeStrict раскладывает YAML (isYAML) или JSON в v. Неизвестные ключи —
+// ошибка, чтобы опечатка в имени параметра не проходила молча.
+func decodeStrict(data []byte, isYAML bool, v interface{}) error {
+	if isYAML {
+		tree, err := parseYAML(data)
+		if err != nil {
+			return err
+		}
+		if data, err = json.Marshal(tree); err != nil {
+			return err
+		}
+	}
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(v); err != nil {
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+// ------------------------------
+// Мини-парсер YAML (раздел (8))
+// ------------------------------
+
+func TestParseYAML(t *testing.T) {
+	// Дерево сравниваем в виде JSON: json.Marshal сортирует ключи словарей
+	tests := []struct {
+		in   string
+		want string
+	}{
+		{"", `{}`},
+		{"# только комментарий\n---\n", `{}`},
+		{"a: 1\nb: text\nc: true\nd: null\ne: ~", `{"a":1,"b":"text","c":true,"d":null,"e":null}`},
+		{"a: \"x # y\"\nb: 'it''s' # комментарий\nc: a#b", `{"a":"x # y","b":"it's","c":"a#b"}`},
+		{"a:\n  b:\n    c: 1\n  d: 2", `{"a":{"b":{"c":1},"d":2}}`},
+		{"list:\n  - 1\n  - two\n  - [a, 'b']", `{"list":[1,"two",["a","b"]]}`},
+		{"list:\n- a\n- b\nnext: 1", `{"list":["a","b"],"next":1}`},
+		{"hooks:\n  - url: \"http://h\"\n    events: [done]\n  - url: x", `{"hooks":[{"events":["done"],"url":"http://h"},{"url":"x"}]}`},
+		{"-\n  a: 1\n-\n- b", `[{"a":1},null,"b"]`},
+		{"empty: []\nobj: {}\nnothing:", `{"empty":[],"nothing":null,"obj":{}}`},
+		{"a: 1\r\nb: 2\r\n", `{"a":1,"b":2}`},
+	}
+	for _, tt := range tests {
+		v, err := parseYAML([]byte(tt.in))
+		if err != nil {
+			t.Errorf("parseYAML(%q): %v", tt.in, err)
+			continue
+		}
+		got, _ := json.Marshal(v)
+		if string(got) != tt.want {
+			t.Errorf("parseYAML(%q) = %s, ожидалось %s", tt.in, got, tt.want)
+		}
+	}
+	for _, in := range []string{
+		"a: 1\n\tb: 2",
+		"a: 1\na: 2",
+		"a: 1\n  b: 2",
+		"just text",
+		"a:\n  - 1\n  b: 2",
+		"a: [1, 2",
+		"a: \"open",
+		"a: 'open",
+		"a: 1\n- b",
+	} {
+		if v, err := parseYAML([]byte(in)); err == nil {
+			t.Errorf("parseYAML(%q) = %v, ожидалась ошибка", in, v)
+		}
+	}
+}
+
+func TestDecodeStrictUnknownKey(t *testing.T) {
+	var v struct {
+		Name string `json:"name"`
+	}
+	if err := decodeStrict([]byte("name: x"), true, &v); err != nil || v.Name != "x" {
+		t.Errorf("decodeStrict: %+v, %v", v, err)
+	}
+	if err := decodeStrict([]byte("nmae: x"), true, &v); err == nil {
+		t.Error("decodeStrict с опечаткой в ключе: ожидалась ошибка")
+	}
+}